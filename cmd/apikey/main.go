@@ -0,0 +1,149 @@
+// Command apikey manages the API keys clients of musiclib authenticate with.
+//
+//	apikey [-config .env] create -name NAME [-role reader|editor|admin] [-tenant TENANT]
+//	apikey [-config .env] list
+//	apikey [-config .env] revoke -id ID
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nongrata2/musiclib/internal/auth"
+	"github.com/nongrata2/musiclib/internal/config"
+	"github.com/nongrata2/musiclib/internal/repositories"
+	"github.com/nongrata2/musiclib/internal/tenant"
+)
+
+func main() {
+	var configPath string
+	flag.StringVar(&configPath, "config", ".env", "configuration file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-config file] create -name NAME [-role ROLE] [-tenant TENANT] | list | revoke -id ID\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.MustLoadCfg(configPath)
+
+	// only warnings and errors, the output is meant for the operator
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
+		cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort)
+
+	storage, err := repositories.New(log, dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to connect to db:", err)
+		os.Exit(1)
+	}
+	if err := storage.Migrate(); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to migrate db:", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	args := flag.Args()[1:]
+
+	switch flag.Arg(0) {
+	case "create":
+		err = create(ctx, storage, args)
+	case "list":
+		err = list(ctx, storage)
+	case "revoke":
+		err = revoke(ctx, storage, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func create(ctx context.Context, storage *repositories.DB, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	name := fs.String("name", "", "who the key is for")
+	roleName := fs.String("role", string(auth.RoleReader), "role granted by the key: reader, editor or admin")
+	tenantID := fs.String("tenant", tenant.Default, "tenant whose library the key gives access to")
+	fs.Parse(args)
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	if *tenantID == "" {
+		return fmt.Errorf("-tenant must not be empty")
+	}
+	role, err := auth.ParseRole(*roleName)
+	if err != nil {
+		return err
+	}
+
+	key, hash, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+
+	apiKey, err := storage.AddAPIKey(ctx, *name, hash, prefix, string(role), *tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to save API key: %w", err)
+	}
+
+	fmt.Printf("created %s API key %d for %s in tenant %s\n", apiKey.Role, apiKey.ID, apiKey.Name, apiKey.TenantID)
+	fmt.Println("it is shown only once, store it securely:")
+	fmt.Println(key)
+	return nil
+}
+
+func list(ctx context.Context, storage *repositories.DB) error {
+	keys, err := storage.GetAPIKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTENANT\tROLE\tPREFIX\tCREATED\tLAST USED\tREVOKED")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.Name, key.TenantID, key.Role, key.Prefix, key.CreatedAt.Format(time.DateTime),
+			formatTime(key.LastUsedAt), formatTime(key.RevokedAt))
+	}
+	return w.Flush()
+}
+
+func revoke(ctx context.Context, storage *repositories.DB, args []string) error {
+	fs := flag.NewFlagSet("revoke", flag.ExitOnError)
+	id := fs.Int("id", 0, "id of the key to revoke")
+	fs.Parse(args)
+
+	if *id < 1 {
+		return fmt.Errorf("-id is required")
+	}
+
+	apiKey, err := storage.RevokeAPIKey(ctx, *id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	fmt.Printf("revoked API key %d for %s\n", apiKey.ID, apiKey.Name)
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.DateTime)
+}
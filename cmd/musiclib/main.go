@@ -5,15 +5,28 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/nongrata2/musiclib/internal/auth"
 	"github.com/nongrata2/musiclib/internal/config"
+	"github.com/nongrata2/musiclib/internal/enrichment"
+	"github.com/nongrata2/musiclib/internal/externalapi"
 	"github.com/nongrata2/musiclib/internal/handlers"
+	"github.com/nongrata2/musiclib/internal/health"
+	"github.com/nongrata2/musiclib/internal/logging"
+	"github.com/nongrata2/musiclib/internal/metrics"
+	"github.com/nongrata2/musiclib/internal/middleware"
+	"github.com/nongrata2/musiclib/internal/ratelimit"
 	"github.com/nongrata2/musiclib/internal/repositories"
+	"github.com/nongrata2/musiclib/internal/tenant"
+	"github.com/nongrata2/musiclib/internal/tracing"
 )
 
 func main() {
@@ -23,12 +36,25 @@ func main() {
 
 	cfg := config.MustLoadCfg(configPath)
 
-	log := mustMakeLogger(cfg.LogLevel)
+	log, logFile := mustMakeLogger(cfg)
 
 	log.Info("starting server")
 
 	log.Debug("debug messages are enabled")
 
+	shutdownTracing, err := tracing.Setup(context.Background(), log, tracing.Config{
+		ServiceName:  "musiclib",
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		OTLPInsecure: cfg.TracingOTLPInsecure,
+		File:         cfg.TracingFile,
+		SampleRatio:  cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		log.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
 	// db
 
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
@@ -39,54 +65,316 @@ func main() {
 		log.Error("failed to connect to db", "error", err)
 		os.Exit(1)
 	}
+	storage.TraceStatements(cfg.TracingSQLStatements)
 	if err := storage.Migrate(); err != nil {
 		log.Error("failed to migrate db", "error", err)
 		os.Exit(1)
 	}
+	if err := storage.UseRole(context.Background(), cfg.DBAppRole); err != nil {
+		log.Error("failed to switch to the application database role", "error", err)
+		os.Exit(1)
+	}
 
 	log.Info("successfully connected to database")
 
+	appMetrics := metrics.New()
+	appMetrics.MustRegister(
+		metrics.NewPoolCollector(storage.Stat),
+		metrics.NewQueueCollector(storage, 5*time.Second),
+	)
+
 	mux := http.NewServeMux()
 
-	externalAPIURL := cfg.ExternalAPIURL
+	externalAPI := externalapi.NewClient(log, &http.Client{Transport: tracing.Transport(http.DefaultTransport)}, externalapi.Config{
+		BaseURL:          cfg.ExternalAPIURL,
+		Timeout:          cfg.ExternalAPITimeout,
+		MaxRetries:       cfg.ExternalAPIMaxRetries,
+		RetryBackoff:     cfg.ExternalAPIRetryBackoff,
+		BreakerThreshold: cfg.ExternalAPIBreakerThreshold,
+		BreakerCooldown:  cfg.ExternalAPIBreakerCooldown,
+		Observer:         appMetrics,
+	})
+
+	songInfo, pgCache, err := makeSongInfoProvider(log, cfg, storage, externalAPI, appMetrics)
+	if err != nil {
+		log.Error("failed to set up song info cache", "error", err)
+		os.Exit(1)
+	}
+
+	read := ratelimit.New(ratelimit.BudgetRead, ratelimit.Config{
+		Rate:       cfg.RateLimitReadRate,
+		Burst:      cfg.RateLimitReadBurst,
+		DailyQuota: cfg.RateLimitReadDailyQuota,
+	}, storage)
+	enrich := ratelimit.New(ratelimit.BudgetEnrich, ratelimit.Config{
+		Rate:       cfg.RateLimitEnrichRate,
+		Burst:      cfg.RateLimitEnrichBurst,
+		DailyQuota: cfg.RateLimitEnrichDailyQuota,
+	}, storage)
+
+	timeout, bulk := cfg.HttpRouteTimeout, cfg.HttpBulkRouteTimeout
+
+	// routes lists every endpoint with the least role allowed to call it,
+	// the rate limit budget it is counted against and the time it may take.
+	// Routes that may call the external API share the smaller enrich budget.
+	routes := []struct {
+		pattern string
+		role    auth.Role
+		budget  *ratelimit.Limiter
+		timeout time.Duration
+		handler http.Handler
+	}{
+		{"PUT /songs", auth.RoleEditor, enrich, timeout, handlers.AddSongHandler(log, storage, songInfo)},
+		{"POST /songs:import", auth.RoleEditor, enrich, bulk, handlers.ImportSongsHandler(log, storage, songInfo, cfg.ImportMaxRows, cfg.ImportMaxEnrichRows)},
+		{"PUT /songs/{songID}", auth.RoleEditor, read, timeout, handlers.EditSongHandler(log, storage)},
+		{"PATCH /songs/{songID}", auth.RoleEditor, read, timeout, handlers.PatchSongHandler(log, storage)},
+		{"GET /songs", auth.RoleReader, read, timeout, handlers.GetLibDataHandler(log, storage, cfg.PageSizeDefault, cfg.PageSizeMax)},
+		{"GET /songs/export", auth.RoleReader, read, bulk, handlers.ExportSongsHandler(log, storage)},
+		{"GET /songs/{songID}", auth.RoleReader, read, timeout, handlers.GetLyricsHandler(log, storage)},
+		{"DELETE /songs/{songID}", auth.RoleAdmin, read, timeout, handlers.DeleteSongHandler(log, storage)},
+
+		{"GET /groups", auth.RoleReader, read, timeout, handlers.GetGroupsDataHandler(log, storage)},
+		{"GET /groups/{groupID}", auth.RoleReader, read, timeout, handlers.GetGroupHandler(log, storage)},
+		{"POST /groups", auth.RoleEditor, read, timeout, handlers.AddGroupHandler(log, storage)},
+		{"PATCH /groups/{groupID}", auth.RoleEditor, read, timeout, handlers.EditGroupHandler(log, storage)},
+		{"DELETE /groups/{groupID}", auth.RoleAdmin, read, timeout, handlers.DeleteGroupHandler(log, storage)},
+
+		{"GET /playlists", auth.RoleReader, read, timeout, handlers.GetPlaylistsHandler(log, storage)},
+		{"GET /playlists/{playlistID}", auth.RoleReader, read, timeout, handlers.GetPlaylistHandler(log, storage)},
+		{"POST /playlists", auth.RoleEditor, read, timeout, handlers.AddPlaylistHandler(log, storage)},
+		{"PATCH /playlists/{playlistID}", auth.RoleEditor, read, timeout, handlers.EditPlaylistHandler(log, storage)},
+		{"DELETE /playlists/{playlistID}", auth.RoleEditor, read, timeout, handlers.DeletePlaylistHandler(log, storage)},
+		{"POST /playlists/{playlistID}/songs", auth.RoleEditor, read, timeout, handlers.AddPlaylistSongHandler(log, storage)},
+		{"PATCH /playlists/{playlistID}/songs/{songID}", auth.RoleEditor, read, timeout, handlers.MovePlaylistSongHandler(log, storage)},
+		{"DELETE /playlists/{playlistID}/songs/{songID}", auth.RoleEditor, read, timeout, handlers.RemovePlaylistSongHandler(log, storage)},
+
+		{"GET /jobs/{jobID}", auth.RoleReader, read, timeout, handlers.GetJobHandler(log, storage)},
+	}
+
+	for _, route := range routes {
+		handler := middleware.Timeout(route.timeout)(route.handler)
+		if cfg.AuthEnabled {
+			handler = handlers.RequireRole(log, route.role, handler)
+		}
+		if cfg.RateLimitEnabled {
+			handler = handlers.RateLimit(log, route.budget, handler)
+		}
+		handler = middleware.Route(route.pattern)(handler)
+		handler = tracing.Route(route.pattern, handler)
+		mux.Handle(route.pattern, handler)
+	}
+
+	// requests are counted per address before they are authenticated, so
+	// that a client with bad or no credentials cannot make lookups unchecked
+	address := ratelimit.New(ratelimit.BudgetAddress, ratelimit.Config{
+		Rate:  cfg.RateLimitAddressRate,
+		Burst: cfg.RateLimitAddressBurst,
+	}, nil)
+
+	handler, err := withAuth(log, cfg, storage, mux)
+	if err != nil {
+		log.Error("failed to set up authentication", "error", err)
+		os.Exit(1)
+	}
+	if cfg.AuthEnabled && cfg.RateLimitEnabled {
+		handler = handlers.RateLimit(log, address, handler)
+	}
 
-	mux.Handle("PUT /songs", handlers.AddSongHandler(log, storage, externalAPIURL))
-	mux.Handle("PUT /songs/{songID}", handlers.EditSongHandler(log, storage))
-	mux.Handle("GET /songs", handlers.GetLibDataHandler(log, storage))
-	mux.Handle("GET /songs/{songID}", handlers.GetLyricsHandler(log, storage))
-	mux.Handle("DELETE /songs/{songID}", handlers.DeleteSongHandler(log, storage))
-	// TO DO
-	// mux.Handle("PUT /groups", handlers.AddGroupHandler(log, storage)) // add new group
-	// mux.Handle("PUT /groups", handlers.GetGroupsDataHandler(log, storage)) // get info about groups, group name: num of songs
+	checker := health.New(log, cfg.HealthCheckTimeout)
+	checker.Add("database", storage.Ping)
+	checker.Add("migrations", storage.CheckMigrations)
+	if cfg.HealthCheckExternalAPI {
+		checker.AddOptional("external_api", externalAPI.Probe)
+	}
+
+	onError := func(w http.ResponseWriter, r *http.Request, err error) {
+		handlers.WriteError(log, w, r, err)
+	}
+
+	// the probes are not authenticated, traced or logged
+	root := http.NewServeMux()
+	root.Handle("GET /healthz", checker.LiveHandler())
+	root.Handle("GET /readyz", checker.ReadyHandler())
+	// the span is started first, so that it covers the whole request,
+	// including the response of a recovered panic; requests are counted under
+	// the route they ask for before they are authenticated
+	routePattern := func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+	root.Handle("/", middleware.Chain(handler,
+		tracing.Middleware,
+		appMetrics.Instrument(routePattern),
+		middleware.RequestID(log),
+		middleware.AccessLog(log),
+		middleware.Recover(log, onError),
+	))
 
 	server := http.Server{
 		Addr:        cfg.HttpServerAddress,
 		ReadTimeout: cfg.HttpServerTimeout * time.Second,
-		Handler:     mux,
+		Handler:     root,
 	}
 
 	log.Info("server is listening on", "address", cfg.HttpServerAddress)
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	worker := enrichment.NewWorker(log, storage, songInfo, enrichment.Config{
+		Workers:      cfg.EnrichmentWorkers,
+		MaxAttempts:  cfg.EnrichmentMaxAttempts,
+		PollInterval: cfg.EnrichmentPollInterval,
+		RetryBackoff: cfg.EnrichmentRetryBackoff,
+		MaxBackoff:   cfg.EnrichmentMaxBackoff,
+		Lease:        cfg.EnrichmentLease,
+	})
+
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		worker.Run(ctx)
+	}()
+	if pgCache != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			pgCache.RunPurge(ctx, cfg.CachePurgeInterval)
+		}()
+	}
+
 	go func() {
 		<-ctx.Done()
-		log.Debug("shutting down server")
+		// fail readiness first, so that no new requests are routed here
+		checker.ShutDown()
+		log.Debug("shutting down server", "delay", cfg.ShutdownDelay)
+		time.Sleep(cfg.ShutdownDelay)
 		if err := server.Shutdown(context.Background()); err != nil {
 			log.Error("erroneous shutdown", "error", err)
 		}
 	}()
 
+	if cfg.AdminServerAddress != "" {
+		go serveAdmin(ctx, log, cfg.AdminServerAddress, appMetrics)
+	}
+
 	if err := server.ListenAndServe(); err != nil {
 		if !errors.Is(err, http.ErrServerClosed) {
 			log.Error("server closed unexpectedly", "error", err)
-			return
 		}
 	}
 
+	// let the workers finish their current jobs
+	stop()
+	workers.Wait()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error("failed to flush traces", "error", err)
+	}
+
+	if logFile != nil {
+		logFile.Close()
+	}
+}
+
+// serveAdmin serves the operational endpoints on their own address, so they
+// can be kept off the public network. It stops when ctx is done.
+func serveAdmin(ctx context.Context, log *slog.Logger, address string, appMetrics *metrics.Metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", appMetrics.Handler())
+
+	server := http.Server{
+		Addr:        address,
+		Handler:     mux,
+		ReadTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Error("erroneous admin server shutdown", "error", err)
+		}
+	}()
+
+	log.Info("admin server is listening on", "address", address)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("admin server closed unexpectedly", "error", err)
+	}
+}
+
+// makeSongInfoProvider puts the configured cache in front of the external API
+// client. The postgres cache is returned as well, for its expired entries to
+// be purged.
+func makeSongInfoProvider(log *slog.Logger, cfg config.Config, storage *repositories.DB,
+	client *externalapi.Client, appMetrics *metrics.Metrics) (externalapi.Fetcher, *repositories.SongInfoCache, error) {
+	var cache externalapi.Cache
+	var pgCache *repositories.SongInfoCache
+	var size func() int
+
+	switch cfg.CacheBackend {
+	case "none":
+		log.Info("song info cache is disabled")
+		return client, nil, nil
+	case "memory":
+		lru := externalapi.NewLRUCache(cfg.CacheSize)
+		cache, size = lru, lru.Len
+	case "postgres":
+		pgCache = repositories.NewSongInfoCache(storage)
+		cache = pgCache
+	default:
+		return nil, nil, fmt.Errorf("unknown cache backend %q", cfg.CacheBackend)
+	}
+
+	log.Info("song info cache is enabled", "backend", cfg.CacheBackend, "ttl", cfg.CacheTTL)
+	cached := externalapi.NewCachedClient(log, client, cache, cfg.CacheTTL, cfg.CacheNegativeTTL)
+	appMetrics.MustRegister(metrics.NewCacheCollectors(cached.Stats, size)...)
+	return cached, pgCache, nil
 }
 
-func mustMakeLogger(logLevel string) *slog.Logger {
-	return slog.Default()
+// withAuth puts the authentication middleware in front of the handler, unless
+// it is disabled. Requests then act for the default tenant.
+func withAuth(log *slog.Logger, cfg config.Config, storage *repositories.DB, handler http.Handler) (http.Handler, error) {
+	if !cfg.AuthEnabled {
+		log.Warn("authentication is disabled, the API is open to everyone")
+		return tenant.Handler(tenant.Default, handler), nil
+	}
+
+	verifier, err := auth.NewJWTVerifier(auth.JWTConfig{
+		HMACSecret:    cfg.AuthJWTSecret,
+		PublicKeyFile: cfg.AuthJWTPublicKeyFile,
+		JWKSFile:      cfg.AuthJWTJWKSFile,
+		Issuer:        cfg.AuthJWTIssuer,
+		Audience:      cfg.AuthJWTAudience,
+		Leeway:        cfg.AuthJWTLeeway,
+		RolesClaim:    cfg.AuthJWTRolesClaim,
+		TenantClaim:   cfg.AuthJWTTenantClaim,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info("authentication is enabled", "jwt", verifier != nil)
+
+	onError := func(w http.ResponseWriter, r *http.Request, err error) {
+		handlers.WriteError(log, w, r, err)
+	}
+	return auth.NewAuthenticator(log, storage, verifier, onError).Middleware(handler), nil
+}
+
+// mustMakeLogger builds the configured logger and makes it the default one.
+// The file it writes to, if any, is returned to be closed on exit.
+func mustMakeLogger(cfg config.Config) (*slog.Logger, io.Closer) {
+	log, closer, err := logging.New(logging.Config{
+		Level:  cfg.LogLevel,
+		Format: cfg.LogFormat,
+		Output: cfg.LogOutput,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up logging: %s\n", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(log)
+	return log, closer
 }
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/nongrata2/musiclib/internal/models"
+)
+
+// APIKeyPrefix starts every API key.
+const APIKeyPrefix = "mlk_"
+
+// KeyStore finds API keys by their hash.
+type KeyStore interface {
+	// LookupAPIKey returns the active key with the hash, or nil if there is none.
+	LookupAPIKey(ctx context.Context, hash []byte) (*models.APIKey, error)
+}
+
+// GenerateAPIKey returns a new random key together with its hash and the
+// prefix shown when listing keys.
+func GenerateAPIKey() (key string, hash []byte, prefix string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, HashAPIKey(key), key[:len(APIKeyPrefix)+6], nil
+}
+
+// HashAPIKey returns the hash a key is stored by. Keys are long and random,
+// so a plain SHA-256 is enough.
+func HashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+func (a *Authenticator) apiKey(ctx context.Context, key string) (*Principal, error) {
+	apiKey, err := a.keys.LookupAPIKey(ctx, HashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	principal := &Principal{
+		Subject: fmt.Sprintf("api_key:%d", apiKey.ID),
+		Name:    apiKey.Name,
+		Method:  MethodAPIKey,
+		Tenant:  apiKey.TenantID,
+	}
+	if role, err := ParseRole(apiKey.Role); err == nil {
+		principal.Roles = []Role{role}
+	}
+	return principal, nil
+}
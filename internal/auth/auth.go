@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	stdErrors "errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/nongrata2/musiclib/internal/tenant"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+// Authentication methods.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Principal is the authenticated client of a request.
+type Principal struct {
+	// Subject identifies the client: "api_key:<id>" for API keys, the sub
+	// claim for tokens.
+	Subject string
+	Name    string
+	Method  string
+	Roles   []Role
+	// Tenant owns the library the principal works with.
+	Tenant string
+}
+
+// LogValue lets a principal be logged as a group of attributes.
+func (p *Principal) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("subject", p.Subject),
+		slog.String("name", p.Name),
+		slog.String("method", p.Method),
+		slog.Any("roles", p.Roles),
+		slog.String("tenant", p.Tenant),
+	)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the request ctx belongs to, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+var (
+	ErrNoCredentials      = errors.Unauthenticated("authentication required")
+	ErrInvalidCredentials = errors.Unauthenticated("invalid credentials")
+)
+
+// Authenticator is a middleware accepting API keys and JWT bearer tokens.
+// API keys are sent in the X-API-Key header or as bearer tokens, which tell
+// them apart from JWTs by their prefix.
+type Authenticator struct {
+	log     *slog.Logger
+	keys    KeyStore
+	jwt     *JWTVerifier
+	onError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewAuthenticator creates the middleware. jwt may be nil to accept API keys
+// only. onError writes the response for rejected requests.
+func NewAuthenticator(log *slog.Logger, keys KeyStore, jwt *JWTVerifier,
+	onError func(w http.ResponseWriter, r *http.Request, err error)) *Authenticator {
+	return &Authenticator{
+		log:     log,
+		keys:    keys,
+		jwt:     jwt,
+		onError: onError,
+	}
+}
+
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="musiclib"`)
+			a.onError(w, r, err)
+			return
+		}
+
+		a.log.Debug("request authenticated", "principal", principal, "path", r.URL.Path)
+		ctx := WithPrincipal(r.Context(), principal)
+		next.ServeHTTP(w, r.WithContext(tenant.WithID(ctx, principal.Tenant)))
+	})
+}
+
+func (a *Authenticator) authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.apiKey(r.Context(), key)
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, ErrNoCredentials
+	}
+	token = strings.TrimSpace(token)
+
+	if strings.HasPrefix(token, APIKeyPrefix) {
+		return a.apiKey(r.Context(), token)
+	}
+
+	if a.jwt == nil {
+		return nil, ErrInvalidCredentials.Wrap(stdErrors.New("JWT authentication is not configured"))
+	}
+	return a.jwt.Verify(token)
+}
@@ -0,0 +1,354 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nongrata2/musiclib/internal/tenant"
+)
+
+type JWTConfig struct {
+	// HMACSecret verifies HS256 tokens. Empty disables them.
+	HMACSecret string
+	// PublicKeyFile is a PEM file with the RSA public key or certificate
+	// verifying RS256 tokens.
+	PublicKeyFile string
+	// JWKSFile is a JSON Web Key Set file with RSA keys verifying RS256
+	// tokens, chosen by the kid header.
+	JWKSFile string
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// Leeway allows for clock skew when checking exp and nbf.
+	Leeway time.Duration
+	// RolesClaim names the claim holding the principal's roles, either a
+	// single role or an array of them.
+	RolesClaim string
+	// TenantClaim names the claim holding the principal's tenant, which
+	// tokens must have. If it is empty, every token acts for the default tenant.
+	TenantClaim string
+}
+
+// JWTVerifier checks HS256 and RS256 signed tokens.
+type JWTVerifier struct {
+	cfg        JWTConfig
+	hmacSecret []byte
+	// rsaKeys are keyed by kid; the key of a PEM file has an empty kid
+	rsaKeys map[string]*rsa.PublicKey
+	now     func() time.Time
+}
+
+// NewJWTVerifier loads the configured keys. It returns nil if no key is
+// configured at all.
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		cfg:     cfg,
+		rsaKeys: make(map[string]*rsa.PublicKey),
+		now:     time.Now,
+	}
+
+	if cfg.HMACSecret != "" {
+		v.hmacSecret = []byte(cfg.HMACSecret)
+	}
+
+	if cfg.PublicKeyFile != "" {
+		key, err := loadPEMPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.rsaKeys[""] = key
+	}
+
+	if cfg.JWKSFile != "" {
+		if err := v.loadJWKS(cfg.JWKSFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if v.hmacSecret == nil && len(v.rsaKeys) == 0 {
+		return nil, nil
+	}
+	return v, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Name      string   `json:"name"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+
+	// roles and tenant are read from the configured claims
+	roles  []Role
+	tenant string
+}
+
+// audience is the aud claim, which is either a string or an array of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Verify checks the token's signature and claims and returns its principal.
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	claims, err := v.verify(token)
+	if err != nil {
+		return nil, ErrInvalidCredentials.Wrap(err)
+	}
+
+	return &Principal{
+		Subject: claims.Subject,
+		Name:    claims.Name,
+		Method:  MethodJWT,
+		Roles:   claims.roles,
+		Tenant:  claims.tenant,
+	}, nil
+}
+
+func (v *JWTVerifier) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	if err := v.verifySignature(header, signed, signature); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if v.cfg.RolesClaim != "" || v.cfg.TenantClaim != "" {
+		var all map[string]json.RawMessage
+		if err := decodeSegment(parts[1], &all); err != nil {
+			return nil, fmt.Errorf("malformed token claims: %w", err)
+		}
+		if v.cfg.RolesClaim != "" {
+			claims.roles = claimRoles(all[v.cfg.RolesClaim])
+		}
+		if v.cfg.TenantClaim != "" {
+			// a tenant of another type is treated as missing
+			json.Unmarshal(all[v.cfg.TenantClaim], &claims.tenant)
+		}
+	}
+	if v.cfg.TenantClaim == "" {
+		claims.tenant = tenant.Default
+	}
+	if err := v.checkClaims(&claims); err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
+
+func (v *JWTVerifier) verifySignature(header jwtHeader, signed, signature []byte) error {
+	// the algorithm is checked against the configured keys, so a token
+	// cannot downgrade itself to "none" or pass an RSA key off as a secret
+	switch header.Alg {
+	case "HS256":
+		if v.hmacSecret == nil {
+			return fmt.Errorf("HS256 tokens are not accepted")
+		}
+		mac := hmac.New(sha256.New, v.hmacSecret)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return fmt.Errorf("invalid token signature")
+		}
+		return nil
+	case "RS256":
+		key, err := v.rsaKey(header.Kid)
+		if err != nil {
+			return err
+		}
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("invalid token signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+}
+
+func (v *JWTVerifier) rsaKey(kid string) (*rsa.PublicKey, error) {
+	if key, ok := v.rsaKeys[kid]; ok {
+		return key, nil
+	}
+	// a token without kid is fine as long as there is only one key to try
+	if kid == "" && len(v.rsaKeys) == 1 {
+		for _, key := range v.rsaKeys {
+			return key, nil
+		}
+	}
+	if len(v.rsaKeys) == 0 {
+		return nil, fmt.Errorf("RS256 tokens are not accepted")
+	}
+	return nil, fmt.Errorf("unknown token key %q", kid)
+}
+
+func (v *JWTVerifier) checkClaims(claims *jwtClaims) error {
+	now := v.now()
+
+	if claims.Subject == "" {
+		return fmt.Errorf("token has no subject")
+	}
+	if claims.tenant == "" {
+		return fmt.Errorf("token has no tenant")
+	}
+	if claims.ExpiresAt == nil {
+		return fmt.Errorf("token has no expiration time")
+	}
+	if now.After(numericDate(*claims.ExpiresAt).Add(v.cfg.Leeway)) {
+		return fmt.Errorf("token is expired")
+	}
+	if claims.NotBefore != nil && now.Add(v.cfg.Leeway).Before(numericDate(*claims.NotBefore)) {
+		return fmt.Errorf("token is not valid yet")
+	}
+	if v.cfg.Issuer != "" && claims.Issuer != v.cfg.Issuer {
+		return fmt.Errorf("unexpected token issuer %q", claims.Issuer)
+	}
+	if v.cfg.Audience != "" && !slices.Contains(claims.Audience, v.cfg.Audience) {
+		return fmt.Errorf("token is not meant for this audience")
+	}
+
+	return nil
+}
+
+func numericDate(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func loadPEMPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT public key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT certificate: %w", err)
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT public key: %w", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("JWT public key in %s is not an RSA key", path)
+	}
+	return rsaKey, nil
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// loadJWKS adds the RSA signing keys of a JWKS file. Other keys are skipped.
+func (v *JWTVerifier) loadJWKS(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS: %w", err)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || k.Use != "" && k.Use != "sig" || k.Alg != "" && k.Alg != "RS256" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return fmt.Errorf("invalid modulus of JWKS key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return fmt.Errorf("invalid exponent of JWKS key %q: %w", k.Kid, err)
+		}
+
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return fmt.Errorf("invalid exponent of JWKS key %q", k.Kid)
+		}
+
+		v.rsaKeys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(exponent.Int64()),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	stdErrors "errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+var testNow = time.Unix(1_700_000_000, 0)
+
+func segment(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func hs256(secret []byte, signed string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func rs256(t *testing.T, key *rsa.PrivateKey, signed string) string {
+	t.Helper()
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// claims returns valid claims, changed by the given pairs; a nil value
+// removes the claim.
+func claims(pairs ...any) map[string]any {
+	c := map[string]any{
+		"sub": "alice",
+		"exp": testNow.Add(time.Hour).Unix(),
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		if pairs[i+1] == nil {
+			delete(c, pairs[i].(string))
+			continue
+		}
+		c[pairs[i].(string)] = pairs[i+1]
+	}
+	return c
+}
+
+func TestJWTVerifierVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newVerifier := func(secret string) *JWTVerifier {
+		return &JWTVerifier{
+			cfg:        JWTConfig{Leeway: 30 * time.Second},
+			hmacSecret: []byte(secret),
+			rsaKeys:    map[string]*rsa.PublicKey{"k1": &rsaKey.PublicKey},
+			now:        func() time.Time { return testNow },
+		}
+	}
+	both := newVerifier(testSecret)
+	rsaOnly := newVerifier("")
+	rsaOnly.hmacSecret = nil
+
+	hsToken := func(secret string, c map[string]any) string {
+		signed := segment(t, map[string]any{"alg": "HS256", "typ": "JWT"}) + "." + segment(t, c)
+		return signed + "." + hs256([]byte(secret), signed)
+	}
+	rsToken := func(kid string, key *rsa.PrivateKey, c map[string]any) string {
+		signed := segment(t, map[string]any{"alg": "RS256", "kid": kid}) + "." + segment(t, c)
+		return signed + "." + rs256(t, key, signed)
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := strings.Split(hsToken(testSecret, claims()), ".")
+	tampered[1] = segment(t, claims("sub", "mallory"))
+
+	tests := []struct {
+		name     string
+		verifier *JWTVerifier
+		token    string
+		wantErr  bool
+	}{
+		// signatures
+		{name: "HS256", verifier: both, token: hsToken(testSecret, claims())},
+		{name: "RS256", verifier: both, token: rsToken("k1", rsaKey, claims())},
+		{name: "RS256 without kid and a single key", verifier: both, token: rsToken("", rsaKey, claims())},
+		{name: "HS256 with another secret", verifier: both, token: hsToken("other-secret", claims()), wantErr: true},
+		{name: "RS256 with another key", verifier: both, token: rsToken("k1", otherKey, claims()), wantErr: true},
+		{name: "RS256 with an unknown kid", verifier: both, token: rsToken("k2", rsaKey, claims()), wantErr: true},
+		{name: "claims changed after signing", verifier: both, token: strings.Join(tampered, "."), wantErr: true},
+		{name: "signature removed", verifier: both, token: strings.Join(tampered[:2], ".") + ".", wantErr: true},
+		{name: "malformed", verifier: both, token: "a.b", wantErr: true},
+
+		// algorithms
+		{
+			name:     "alg none",
+			verifier: both,
+			token:    segment(t, map[string]any{"alg": "none"}) + "." + segment(t, claims()) + ".",
+			wantErr:  true,
+		},
+		{
+			name:     "unsupported alg",
+			verifier: both,
+			token:    segment(t, map[string]any{"alg": "HS512"}) + "." + segment(t, claims()) + "." + hs256([]byte(testSecret), "x"),
+			wantErr:  true,
+		},
+		{
+			name:     "HS256 signed with the RSA public key",
+			verifier: rsaOnly,
+			token:    hsToken(string(publicKey), claims()),
+			wantErr:  true,
+		},
+
+		// exp and nbf
+		{name: "no exp", verifier: both, token: hsToken(testSecret, claims("exp", nil)), wantErr: true},
+		{name: "expired", verifier: both, token: hsToken(testSecret, claims("exp", testNow.Add(-time.Minute).Unix())), wantErr: true},
+		{name: "expired within leeway", verifier: both, token: hsToken(testSecret, claims("exp", testNow.Add(-10*time.Second).Unix()))},
+		{name: "not valid yet", verifier: both, token: hsToken(testSecret, claims("nbf", testNow.Add(time.Minute).Unix())), wantErr: true},
+		{name: "not valid yet within leeway", verifier: both, token: hsToken(testSecret, claims("nbf", testNow.Add(10*time.Second).Unix()))},
+		{name: "valid since", verifier: both, token: hsToken(testSecret, claims("nbf", testNow.Add(-time.Minute).Unix()))},
+		{name: "no subject", verifier: both, token: hsToken(testSecret, claims("sub", nil)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := tt.verifier.Verify(tt.token)
+			if tt.wantErr {
+				if !stdErrors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("Verify = %v, %v, want %v", principal, err, ErrInvalidCredentials)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if principal.Subject != "alice" || principal.Method != MethodJWT {
+				t.Errorf("Verify = %+v, want subject alice authenticated by JWT", principal)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Role grants access to a set of operations. Every role includes the ones
+// ranked below it: editors can do everything readers can, admins everything.
+type Role string
+
+const (
+	RoleReader Role = "reader"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleReader: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// ParseRole returns the role with the given name.
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role %q, expected reader, editor or admin", name)
+	}
+	return role, nil
+}
+
+// Includes reports whether the role grants everything required grants.
+func (r Role) Includes(required Role) bool {
+	rank, ok := roleRanks[r]
+	return ok && rank >= roleRanks[required]
+}
+
+// HasRole reports whether any role of the principal includes required.
+func (p *Principal) HasRole(required Role) bool {
+	for _, role := range p.Roles {
+		if role.Includes(required) {
+			return true
+		}
+	}
+	return false
+}
+
+// claimRoles reads roles from a claim holding either a single role name or
+// an array of them. Names that are not roles are skipped.
+func claimRoles(raw json.RawMessage) []Role {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var names []string
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		names = []string{single}
+	} else if err := json.Unmarshal(raw, &names); err != nil {
+		return nil
+	}
+
+	var roles []Role
+	for _, name := range names {
+		if role, err := ParseRole(name); err == nil {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
@@ -11,13 +11,83 @@ import (
 type Config struct {
 	HttpServerAddress string        `env:"HTTP_SERVER_ADDRESS" env-default:"localhost:8081"`
 	HttpServerTimeout time.Duration `env:"HTTP_SERVER_TIMEOUT" env-default:"5s"`
-	LogLevel          string        `env:"LOG_LEVEL" env-default:"DEBUG"`
-	DBHost            string        `env:"DB_HOST" env-default:"db"`
-	DBUser            string        `env:"POSTGRES_USER" env-default:"postgres"`
-	DBPassword        string        `env:"POSTGRES_PASSWORD" env-default:"postgres"`
-	DBName            string        `env:"POSTGRES_NAME" env-default:"postgres"`
-	DBPort            string        `env:"POSTGRES_PORT" env-default:"5432"`
-	ExternalAPIURL    string        `env:"EXTERNAL_APIURL" env-default:"http://172.17.0.1:8082"`
+	// HttpRouteTimeout limits serving a request, HttpBulkRouteTimeout
+	// the import and export of song lists; zero disables them
+	HttpRouteTimeout     time.Duration `env:"HTTP_ROUTE_TIMEOUT" env-default:"30s"`
+	HttpBulkRouteTimeout time.Duration `env:"HTTP_BULK_ROUTE_TIMEOUT" env-default:"5m"`
+	// AdminServerAddress serves /metrics; empty disables it
+	AdminServerAddress string `env:"ADMIN_SERVER_ADDRESS" env-default:"localhost:9090"`
+	DBHost             string `env:"DB_HOST" env-default:"db"`
+	DBUser             string `env:"POSTGRES_USER" env-default:"postgres"`
+	DBPassword         string `env:"POSTGRES_PASSWORD" env-default:"postgres"`
+	DBName             string `env:"POSTGRES_NAME" env-default:"postgres"`
+	DBPort             string `env:"POSTGRES_PORT" env-default:"5432"`
+	DBAppRole          string `env:"DB_APP_ROLE" env-default:"musiclib_app"`
+	ExternalAPIURL     string `env:"EXTERNAL_APIURL" env-default:"http://172.17.0.1:8082"`
+
+	ExternalAPITimeout          time.Duration `env:"EXTERNAL_API_TIMEOUT" env-default:"5s"`
+	ExternalAPIMaxRetries       int           `env:"EXTERNAL_API_MAX_RETRIES" env-default:"3"`
+	ExternalAPIRetryBackoff     time.Duration `env:"EXTERNAL_API_RETRY_BACKOFF" env-default:"200ms"`
+	ExternalAPIBreakerThreshold int           `env:"EXTERNAL_API_BREAKER_THRESHOLD" env-default:"5"`
+	ExternalAPIBreakerCooldown  time.Duration `env:"EXTERNAL_API_BREAKER_COOLDOWN" env-default:"30s"`
+
+	LogLevel  string `env:"LOG_LEVEL" env-default:"DEBUG"`
+	LogFormat string `env:"LOG_FORMAT" env-default:"text"`
+	// LogOutput is stdout, stderr or a file path
+	LogOutput string `env:"LOG_OUTPUT" env-default:"stdout"`
+
+	CacheBackend     string        `env:"CACHE_BACKEND" env-default:"memory"`
+	CacheSize        int           `env:"CACHE_SIZE" env-default:"10000"`
+	CacheTTL         time.Duration `env:"CACHE_TTL" env-default:"24h"`
+	CacheNegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL" env-default:"1h"`
+	// CachePurgeInterval is how often expired entries are removed from the postgres cache
+	CachePurgeInterval time.Duration `env:"CACHE_PURGE_INTERVAL" env-default:"1h"`
+
+	PageSizeDefault int `env:"PAGE_SIZE_DEFAULT" env-default:"20"`
+	PageSizeMax     int `env:"PAGE_SIZE_MAX" env-default:"100"`
+
+	ImportMaxRows int `env:"IMPORT_MAX_ROWS" env-default:"10000"`
+	// ImportMaxEnrichRows caps imports that call the external API for every row
+	ImportMaxEnrichRows int `env:"IMPORT_MAX_ENRICH_ROWS" env-default:"100"`
+
+	AuthEnabled          bool          `env:"AUTH_ENABLED" env-default:"false"`
+	AuthJWTSecret        string        `env:"AUTH_JWT_SECRET"`
+	AuthJWTPublicKeyFile string        `env:"AUTH_JWT_PUBLIC_KEY_FILE"`
+	AuthJWTJWKSFile      string        `env:"AUTH_JWT_JWKS_FILE"`
+	AuthJWTIssuer        string        `env:"AUTH_JWT_ISSUER"`
+	AuthJWTAudience      string        `env:"AUTH_JWT_AUDIENCE"`
+	AuthJWTLeeway        time.Duration `env:"AUTH_JWT_LEEWAY" env-default:"30s"`
+	AuthJWTRolesClaim    string        `env:"AUTH_JWT_ROLES_CLAIM" env-default:"roles"`
+	AuthJWTTenantClaim   string        `env:"AUTH_JWT_TENANT_CLAIM" env-default:"tenant"`
+
+	RateLimitEnabled          bool    `env:"RATE_LIMIT_ENABLED" env-default:"true"`
+	RateLimitReadRate         float64 `env:"RATE_LIMIT_READ_RATE" env-default:"20"`
+	RateLimitReadBurst        int     `env:"RATE_LIMIT_READ_BURST" env-default:"40"`
+	RateLimitReadDailyQuota   int     `env:"RATE_LIMIT_READ_DAILY_QUOTA" env-default:"0"`
+	RateLimitEnrichRate       float64 `env:"RATE_LIMIT_ENRICH_RATE" env-default:"1"`
+	RateLimitEnrichBurst      int     `env:"RATE_LIMIT_ENRICH_BURST" env-default:"5"`
+	RateLimitEnrichDailyQuota int     `env:"RATE_LIMIT_ENRICH_DAILY_QUOTA" env-default:"0"`
+	RateLimitAddressRate      float64 `env:"RATE_LIMIT_ADDRESS_RATE" env-default:"50"`
+	RateLimitAddressBurst     int     `env:"RATE_LIMIT_ADDRESS_BURST" env-default:"100"`
+
+	TracingExporter      string  `env:"TRACING_EXPORTER" env-default:"none"`
+	TracingOTLPEndpoint  string  `env:"TRACING_OTLP_ENDPOINT"`
+	TracingOTLPInsecure  bool    `env:"TRACING_OTLP_INSECURE" env-default:"false"`
+	TracingFile          string  `env:"TRACING_FILE" env-default:"traces.jsonl"`
+	TracingSampleRatio   float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+	TracingSQLStatements bool    `env:"TRACING_SQL_STATEMENTS" env-default:"false"`
+
+	HealthCheckTimeout     time.Duration `env:"HEALTH_CHECK_TIMEOUT" env-default:"2s"`
+	HealthCheckExternalAPI bool          `env:"HEALTH_CHECK_EXTERNAL_API" env-default:"false"`
+	// ShutdownDelay keeps serving after readiness fails on shutdown
+	ShutdownDelay time.Duration `env:"SHUTDOWN_DELAY" env-default:"5s"`
+
+	EnrichmentWorkers      int           `env:"ENRICHMENT_WORKERS" env-default:"2"`
+	EnrichmentMaxAttempts  int           `env:"ENRICHMENT_MAX_ATTEMPTS" env-default:"10"`
+	EnrichmentPollInterval time.Duration `env:"ENRICHMENT_POLL_INTERVAL" env-default:"5s"`
+	EnrichmentRetryBackoff time.Duration `env:"ENRICHMENT_RETRY_BACKOFF" env-default:"30s"`
+	EnrichmentMaxBackoff   time.Duration `env:"ENRICHMENT_MAX_BACKOFF" env-default:"1h"`
+	EnrichmentLease        time.Duration `env:"ENRICHMENT_LEASE" env-default:"2m"`
 }
 
 func MustLoadCfg(configPath string) Config {
@@ -0,0 +1,174 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/nongrata2/musiclib/internal/externalapi"
+	"github.com/nongrata2/musiclib/internal/logging"
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/internal/tenant"
+)
+
+var tracer = otel.Tracer("github.com/nongrata2/musiclib/internal/enrichment")
+
+// Queue is the persistent queue of enrichment jobs.
+type Queue interface {
+	ClaimJob(ctx context.Context, lease time.Duration) (*models.Job, error)
+	CompleteJob(ctx context.Context, id int, details models.Song) error
+	RetryJob(ctx context.Context, id int, lastErr string, runAt time.Time) error
+	FailJob(ctx context.Context, id int, lastErr string) error
+}
+
+type Config struct {
+	// Workers is the number of jobs processed concurrently.
+	Workers int
+	// MaxAttempts is the number of attempts after which a job fails for good.
+	MaxAttempts int
+	// PollInterval is how often an idle worker looks for new jobs.
+	PollInterval time.Duration
+	// RetryBackoff is the delay before the first retry, doubled for every next one.
+	RetryBackoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+	// Lease is how long a job stays claimed by a worker. A job that was not
+	// finished by then is taken again.
+	Lease time.Duration
+}
+
+// Worker fills in details of songs saved while the external API was down.
+type Worker struct {
+	log      *slog.Logger
+	queue    Queue
+	songInfo externalapi.Fetcher
+	cfg      Config
+}
+
+func NewWorker(log *slog.Logger, queue Queue, songInfo externalapi.Fetcher, cfg Config) *Worker {
+	return &Worker{
+		log:      log,
+		queue:    queue,
+		songInfo: songInfo,
+		cfg:      cfg,
+	}
+}
+
+// Run processes jobs until ctx is cancelled. A job interrupted by the
+// cancellation is picked up again once its lease is over.
+func (w *Worker) Run(ctx context.Context) {
+	w.log.Info("starting enrichment workers", "workers", w.cfg.Workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+
+	w.log.Info("enrichment workers stopped")
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		// keep going while there is work, sleep once the queue is drained
+		if w.next(ctx) {
+			continue
+		}
+
+		timer := time.NewTimer(w.cfg.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// next runs the next due job and reports whether there was one.
+func (w *Worker) next(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	job, err := w.queue.ClaimJob(ctx, w.cfg.Lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			w.log.Error("failed to claim enrichment job", "error", err)
+		}
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	log := w.log.With("job_id", job.ID, "song_id", job.SongID, "tenant", job.TenantID, "attempt", job.Attempts)
+	log.Debug("running enrichment job")
+	ctx = logging.WithLogger(ctx, log)
+
+	// the job is claimed across tenants, the rest is done within its own
+	ctx = tenant.WithID(ctx, job.TenantID)
+
+	ctx, span := tracer.Start(ctx, "enrichment.job", trace.WithAttributes(
+		attribute.Int("job.id", job.ID),
+		attribute.Int("job.attempt", job.Attempts),
+		attribute.Int("song.id", job.SongID),
+	))
+	defer span.End()
+
+	apiResponse, err := w.songInfo.GetSongInfo(ctx, job.Group, job.Songname)
+	if ctx.Err() != nil {
+		return false
+	}
+
+	switch {
+	case err == nil:
+		details := models.Song{
+			Text: apiResponse.Text,
+			Link: apiResponse.Link,
+		}
+		if !apiResponse.ReleaseDate.IsZero() {
+			details.ReleaseDate = &apiResponse.ReleaseDate
+		}
+		if err := w.queue.CompleteJob(ctx, job.ID, details); err != nil {
+			log.Error("failed to complete enrichment job", "error", err)
+			return true
+		}
+		log.Info("song enriched")
+	case errors.Is(err, externalapi.ErrNotFound) || !externalapi.IsTransient(err) || job.Attempts >= w.cfg.MaxAttempts:
+		// asking again about an unknown song or after an unexpected answer will not help
+		if err := w.queue.FailJob(ctx, job.ID, err.Error()); err != nil {
+			log.Error("failed to mark enrichment job failed", "error", err)
+			return true
+		}
+		log.Warn("enrichment job failed", "error", err)
+	default:
+		runAt := time.Now().Add(w.backoff(job.Attempts))
+		if err := w.queue.RetryJob(ctx, job.ID, err.Error(), runAt); err != nil {
+			log.Error("failed to reschedule enrichment job", "error", err)
+			return true
+		}
+		log.Warn("enrichment job will be retried", "run_at", runAt, "error", err)
+	}
+
+	return true
+}
+
+// backoff returns the delay before the job runs again after its attempt-th attempt.
+func (w *Worker) backoff(attempt int) time.Duration {
+	delay := w.cfg.RetryBackoff << (attempt - 1)
+	if delay <= 0 || delay > w.cfg.MaxBackoff {
+		return w.cfg.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,146 @@
+package externalapi
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	const cooldown = 30 * time.Second
+
+	// op is a call to the breaker: "allow", "success", "failure" or
+	// "release"; for allow, allowed is the expected answer. The clock is
+	// advanced by wait first.
+	type step struct {
+		wait    time.Duration
+		op      string
+		allowed bool
+		state   breakerState
+	}
+
+	tests := []struct {
+		name      string
+		threshold int
+		steps     []step
+	}{
+		{
+			name:      "opens after threshold consecutive failures",
+			threshold: 3,
+			steps: []step{
+				{op: "failure", state: stateClosed},
+				{op: "failure", state: stateClosed},
+				{op: "allow", allowed: true, state: stateClosed},
+				{op: "failure", state: stateOpen},
+				{op: "allow", allowed: false, state: stateOpen},
+			},
+		},
+		{
+			name:      "success resets the failure count",
+			threshold: 2,
+			steps: []step{
+				{op: "failure", state: stateClosed},
+				{op: "success", state: stateClosed},
+				{op: "failure", state: stateClosed},
+				{op: "failure", state: stateOpen},
+			},
+		},
+		{
+			name:      "rejects calls until the cooldown is over",
+			threshold: 1,
+			steps: []step{
+				{op: "failure", state: stateOpen},
+				{wait: cooldown - time.Second, op: "allow", allowed: false, state: stateOpen},
+				{wait: time.Second, op: "allow", allowed: true, state: stateHalfOpen},
+			},
+		},
+		{
+			name:      "lets a single trial call through",
+			threshold: 1,
+			steps: []step{
+				{op: "failure", state: stateOpen},
+				{wait: cooldown, op: "allow", allowed: true, state: stateHalfOpen},
+				{op: "allow", allowed: false, state: stateHalfOpen},
+				{op: "allow", allowed: false, state: stateHalfOpen},
+			},
+		},
+		{
+			name:      "successful trial closes",
+			threshold: 2,
+			steps: []step{
+				{op: "failure", state: stateClosed},
+				{op: "failure", state: stateOpen},
+				{wait: cooldown, op: "allow", allowed: true, state: stateHalfOpen},
+				{op: "success", state: stateClosed},
+				{op: "allow", allowed: true, state: stateClosed},
+				{op: "failure", state: stateClosed},
+			},
+		},
+		{
+			name:      "failed trial reopens for another cooldown",
+			threshold: 2,
+			steps: []step{
+				{op: "failure", state: stateClosed},
+				{op: "failure", state: stateOpen},
+				{wait: cooldown, op: "allow", allowed: true, state: stateHalfOpen},
+				{op: "failure", state: stateOpen},
+				{wait: cooldown - time.Second, op: "allow", allowed: false, state: stateOpen},
+				{wait: time.Second, op: "allow", allowed: true, state: stateHalfOpen},
+			},
+		},
+		{
+			name:      "released trial lets the next call try",
+			threshold: 1,
+			steps: []step{
+				{op: "failure", state: stateOpen},
+				{wait: cooldown, op: "allow", allowed: true, state: stateHalfOpen},
+				{op: "release", state: stateHalfOpen},
+				{op: "allow", allowed: true, state: stateHalfOpen},
+				{op: "allow", allowed: false, state: stateHalfOpen},
+			},
+		},
+		{
+			name:      "disabled",
+			threshold: 0,
+			steps: []step{
+				{op: "failure", state: stateClosed},
+				{op: "failure", state: stateClosed},
+				{op: "allow", allowed: true, state: stateClosed},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			b := newBreaker(slog.New(slog.NewTextHandler(io.Discard, nil)), tt.threshold, cooldown)
+			b.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				now = now.Add(s.wait)
+				switch s.op {
+				case "allow":
+					if allowed := b.allow(); allowed != s.allowed {
+						t.Errorf("step %d: allow = %v, want %v", i, allowed, s.allowed)
+					}
+				case "success":
+					b.success()
+				case "failure":
+					b.failure()
+				case "release":
+					b.release()
+				default:
+					t.Fatalf("step %d: unknown op %q", i, s.op)
+				}
+
+				if b.state != s.state {
+					t.Errorf("step %d: after %s the breaker is %v, want %v", i, s.op, b.state, s.state)
+				}
+				if open := b.state == stateOpen; b.isOpen() != open {
+					t.Errorf("step %d: isOpen = %v in state %v", i, b.isOpen(), b.state)
+				}
+			}
+		})
+	}
+}
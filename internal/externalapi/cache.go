@@ -0,0 +1,106 @@
+package externalapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Fetcher looks up song details. Both Client and CachedClient implement it.
+type Fetcher interface {
+	GetSongInfo(ctx context.Context, group, song string) (APIResponse, error)
+}
+
+// CacheEntry is a cached lookup result. NotFound marks a negative entry
+// remembering that the external API does not know the song.
+type CacheEntry struct {
+	Response  APIResponse
+	NotFound  bool
+	ExpiresAt time.Time
+}
+
+// Cache stores lookup results by key. Get reports false for missing and
+// expired entries.
+type Cache interface {
+	Get(ctx context.Context, key string) (CacheEntry, bool, error)
+	Set(ctx context.Context, key string, entry CacheEntry) error
+}
+
+// CacheKey normalizes group and song names, so lookups differing only in
+// case or spacing share an entry.
+func CacheKey(group, song string) string {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Join(strings.Fields(s), " "))
+	}
+	return normalize(group) + "\x1f" + normalize(song)
+}
+
+// CachedClient answers lookups from the cache and asks the wrapped fetcher
+// only on a miss. Successful lookups are kept for ttl, unknown songs for
+// negativeTTL (zero disables negative caching).
+type CachedClient struct {
+	log         *slog.Logger
+	next        Fetcher
+	cache       Cache
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func NewCachedClient(log *slog.Logger, next Fetcher, cache Cache, ttl, negativeTTL time.Duration) *CachedClient {
+	return &CachedClient{
+		log:         log,
+		next:        next,
+		cache:       cache,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}
+}
+
+func (c *CachedClient) GetSongInfo(ctx context.Context, group, song string) (APIResponse, error) {
+	key := CacheKey(group, song)
+
+	entry, ok, err := c.cache.Get(ctx, key)
+	if err != nil {
+		// a broken cache must not break lookups
+		c.log.Warn("failed to read song info cache", "error", err)
+	}
+	if ok {
+		hits := c.hits.Add(1)
+		c.log.Debug("song info cache hit", "group", group, "song", song, "negative", entry.NotFound,
+			"hits", hits, "misses", c.misses.Load())
+		if entry.NotFound {
+			return APIResponse{}, ErrNotFound
+		}
+		return entry.Response, nil
+	}
+
+	misses := c.misses.Add(1)
+	c.log.Debug("song info cache miss", "group", group, "song", song, "hits", c.hits.Load(), "misses", misses)
+
+	resp, err := c.next.GetSongInfo(ctx, group, song)
+	switch {
+	case err == nil:
+		c.store(ctx, key, CacheEntry{Response: resp, ExpiresAt: time.Now().Add(c.ttl)})
+	case errors.Is(err, ErrNotFound) && c.negativeTTL > 0:
+		c.store(ctx, key, CacheEntry{NotFound: true, ExpiresAt: time.Now().Add(c.negativeTTL)})
+	}
+
+	return resp, err
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *CachedClient) Stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+func (c *CachedClient) store(ctx context.Context, key string, entry CacheEntry) {
+	if err := c.cache.Set(ctx, key, entry); err != nil {
+		c.log.Warn("failed to write song info cache", "error", err)
+	}
+}
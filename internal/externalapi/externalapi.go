@@ -1,29 +1,268 @@
 package externalapi
 
 import (
-	"time"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/nongrata2/musiclib/internal/externalapi")
+
+var (
+	ErrNotFound    = errors.New("song not found in external API")
+	ErrCircuitOpen = errors.New("external API is unavailable, circuit breaker is open")
 )
 
 type APIResponse struct {
 	ReleaseDate time.Time `json:"release_date"`
-	Text        string `json:"text"`
-	Link        string `json:"link"`
+	Text        string    `json:"text"`
+	Link        string    `json:"link"`
+}
+
+// Outcomes of requests and calls reported to an Observer.
+const (
+	OutcomeSuccess     = "success"
+	OutcomeNotFound    = "not_found"
+	OutcomeError       = "error"
+	OutcomeCircuitOpen = "circuit_open"
+	OutcomeCanceled    = "canceled"
+)
+
+// Observer records the client's work for monitoring.
+type Observer interface {
+	// ObserveRequest records a single HTTP request to the API.
+	ObserveRequest(outcome string, duration time.Duration)
+	// ObserveCall records a lookup, which may span several requests.
+	ObserveCall(outcome string)
+}
+
+type Config struct {
+	BaseURL string
+	// Timeout limits a single attempt.
+	Timeout time.Duration
+	// MaxRetries is the number of attempts made after the first one failed.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled for every next one.
+	RetryBackoff time.Duration
+	// BreakerThreshold is the number of consecutive failed calls that opens
+	// the circuit breaker. Zero disables it.
+	BreakerThreshold int
+	// BreakerCooldown is how long the open breaker rejects calls.
+	BreakerCooldown time.Duration
+	// Observer, if not nil, is told about every request and call.
+	Observer Observer
+}
+
+// Client calls the external song info API.
+type Client struct {
+	log     *slog.Logger
+	http    *http.Client
+	cfg     Config
+	breaker *breaker
+}
+
+func NewClient(log *slog.Logger, httpClient *http.Client, cfg Config) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	if cfg.Observer == nil {
+		cfg.Observer = nopObserver{}
+	}
+	return &Client{
+		log:     log,
+		http:    httpClient,
+		cfg:     cfg,
+		breaker: newBreaker(log, cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// retryableError marks failures that are worth another attempt.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// IsTransient reports whether err is a failure of the external API that is
+// likely to pass: a timeout, a network error, a 5xx or 429 answer, or the
+// circuit breaker being open. Other failures, such as a 4xx answer or a
+// response that cannot be decoded, would only happen again.
+func IsTransient(err error) bool {
+	var retryable *retryableError
+	return errors.As(err, &retryable) || errors.Is(err, ErrCircuitOpen)
 }
 
-func GetDataFromExternalAPI(apiBaseURL, group, song string) (APIResponse, error) {
-	apiURL := fmt.Sprintf("%s/info?group=%s&song=%s", apiBaseURL, url.QueryEscape(group), url.QueryEscape(song))
+// GetSongInfo looks up details of the song, retrying transient failures
+// with exponential backoff. The lookup is traced as a span holding the
+// spans of its requests.
+func (c *Client) GetSongInfo(ctx context.Context, group, song string) (APIResponse, error) {
+	ctx, span := tracer.Start(ctx, "externalapi.GetSongInfo", trace.WithAttributes(
+		attribute.String("song.group", group),
+		attribute.String("song.name", song),
+	))
+	defer span.End()
+
+	resp, err := c.getSongInfo(ctx, group, song)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNotFound):
+		// a valid answer, not a failure
+		span.SetAttributes(attribute.Bool("song.not_found", true))
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return resp, err
+}
+
+func (c *Client) getSongInfo(ctx context.Context, group, song string) (APIResponse, error) {
+	if !c.breaker.allow() {
+		c.log.Warn("external API call rejected by circuit breaker", "group", group, "song", song)
+		c.cfg.Observer.ObserveCall(OutcomeCircuitOpen)
+		return APIResponse{}, ErrCircuitOpen
+	}
+
+	apiURL := fmt.Sprintf("%s/info?group=%s&song=%s", c.cfg.BaseURL, url.QueryEscape(group), url.QueryEscape(song))
+
+	var err error
+	var retryable *retryableError
+	for attempt := 0; ; attempt++ {
+		retryable = nil
+		start := time.Now()
+		var apiResponse APIResponse
+		apiResponse, err = c.get(ctx, apiURL)
+		duration := time.Since(start)
+		c.log.Debug("external API call", "attempt", attempt+1, "duration", duration, "error", err)
+		c.cfg.Observer.ObserveRequest(outcome(err), duration)
+
+		if err == nil || errors.Is(err, ErrNotFound) {
+			c.breaker.success()
+			c.cfg.Observer.ObserveCall(outcome(err))
+			return apiResponse, err
+		}
+
+		if !errors.As(err, &retryable) || attempt >= c.cfg.MaxRetries || ctx.Err() != nil {
+			break
+		}
+
+		delay := c.backoff(attempt)
+		c.log.Warn("external API call failed, retrying", "attempt", attempt+1, "retry_in", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			c.breaker.release()
+			c.cfg.Observer.ObserveCall(OutcomeCanceled)
+			return APIResponse{}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	// only transient failures say the provider is down; a call cancelled
+	// by our own client says nothing and any other answer means it is up
+	callOutcome := OutcomeError
+	switch {
+	case ctx.Err() != nil:
+		c.breaker.release()
+		callOutcome = OutcomeCanceled
+	case retryable != nil:
+		c.breaker.failure()
+	default:
+		c.breaker.success()
+	}
+	c.log.Error("external API call failed", "group", group, "song", song, "error", err)
+	c.cfg.Observer.ObserveCall(callOutcome)
+	return APIResponse{}, err
+}
+
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, ErrNotFound):
+		return OutcomeNotFound
+	default:
+		return OutcomeError
+	}
+}
+
+type nopObserver struct{}
+
+func (nopObserver) ObserveRequest(string, time.Duration) {}
+func (nopObserver) ObserveCall(string)                   {}
+
+// Probe checks that the API can be reached. Any answer short of a server
+// error will do, the API has no endpoint for health checks. The probe does
+// not count towards the circuit breaker, but fails while it is open.
+func (c *Client) Probe(ctx context.Context) error {
+	if c.breaker.isOpen() {
+		return ErrCircuitOpen
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.BaseURL+"/info", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create external API request: %w", err)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call external API: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("external API returned status: %s", resp.Status)
+	}
+	return nil
+}
+
+// backoff returns the delay before retry number attempt+1, with jitter so
+// that concurrent clients do not retry in lockstep.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.cfg.RetryBackoff << attempt
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func (c *Client) get(ctx context.Context, apiURL string) (APIResponse, error) {
+	if c.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return APIResponse{}, fmt.Errorf("failed to create external API request: %w", err)
+	}
 
-	resp, err := http.Get(apiURL)
+	resp, err := c.http.Do(req)
 	if err != nil {
-		return APIResponse{}, fmt.Errorf("failed to call external API: %w", err)
+		return APIResponse{}, &retryableError{fmt.Errorf("failed to call external API: %w", err)}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return APIResponse{}, ErrNotFound
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		return APIResponse{}, &retryableError{fmt.Errorf("external API returned non-OK status: %s", resp.Status)}
+	case resp.StatusCode != http.StatusOK:
 		return APIResponse{}, fmt.Errorf("external API returned non-OK status: %s", resp.Status)
 	}
 
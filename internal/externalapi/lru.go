@@ -0,0 +1,85 @@
+package externalapi
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRUCache is an in-memory Cache holding at most size entries; the least
+// recently used entry is evicted first.
+type LRUCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry CacheEntry
+}
+
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(_ context.Context, key string) (CacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return CacheEntry{}, false, nil
+	}
+
+	item := elem.Value.(*lruItem)
+	if time.Now().After(item.entry.ExpiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return CacheEntry{}, false, nil
+	}
+
+	c.order.MoveToFront(elem)
+	return item.entry, true, nil
+}
+
+func (c *LRUCache) Set(_ context.Context, key string, entry CacheEntry) error {
+	if c.size <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*lruItem).entry = entry
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(&lruItem{key: key, entry: entry})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruItem).key)
+	}
+
+	return nil
+}
+
+// Len returns the number of entries currently held, including expired ones
+// that were not looked up since.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
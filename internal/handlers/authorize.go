@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/nongrata2/musiclib/internal/auth"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+// RequireRole lets the request through to next only if its principal has
+// the role. Requests without a principal are rejected as unauthenticated.
+func RequireRole(log *slog.Logger, role auth.Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			writeError(log, w, r, auth.ErrNoCredentials)
+			return
+		}
+		if !principal.HasRole(role) {
+			writeError(log, w, r, errors.PermissionDenied("the "+string(role)+" role is required").
+				WithDetails(map[string]any{"required_role": role}))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
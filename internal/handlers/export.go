@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/nongrata2/musiclib/internal/logging"
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+// songExportParams are the query parameters GET /songs/export understands besides the filters.
+var songExportParams = []string{"format"}
+
+// songEncoder writes exported songs in one of the export formats.
+type songEncoder interface {
+	begin() error
+	encode(song models.Song) error
+	end() error
+}
+
+// exportFormat describes how songs are exported in a format.
+type exportFormat struct {
+	contentType string
+	extension   string
+	newEncoder  func(w io.Writer) songEncoder
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv": {
+		contentType: "text/csv; charset=utf-8",
+		extension:   "csv",
+		newEncoder:  func(w io.Writer) songEncoder { return &csvSongEncoder{w: csv.NewWriter(w)} },
+	},
+	"ndjson": {
+		contentType: ndjsonContentType,
+		extension:   "ndjson",
+		newEncoder:  func(w io.Writer) songEncoder { return &ndjsonSongEncoder{enc: json.NewEncoder(w)} },
+	},
+	"json": {
+		contentType: "application/json",
+		extension:   "json",
+		newEncoder:  func(w io.Writer) songEncoder { return &jsonSongEncoder{w: w} },
+	},
+}
+
+// ExportSongsHandler streams every song matching the filters of GET /songs
+// as a file download. Songs are written as they are read from the database,
+// so an error in the middle of the export can only cut the response short.
+func ExportSongsHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("exporting songs handler")
+		log.Info("start exporting songs")
+
+		if err := checkParams(r.URL.Query(), songFilterParams, songExportParams); err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		filters, err := parseSongFilter(r.URL.Query())
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		formatName := r.URL.Query().Get("format")
+		if formatName == "" {
+			formatName = "json"
+		}
+		format, ok := exportFormats[formatName]
+		if !ok {
+			writeError(log, w, r, errors.InvalidArgument("format must be one of csv, ndjson, json"))
+			return
+		}
+
+		enc := format.newEncoder(w)
+		started := false
+		start := func() error {
+			// the headers are sent with the first song, so that errors before it
+			// can still be reported properly
+			started = true
+			w.Header().Set("Content-Type", format.contentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="songs.%s"`, format.extension))
+			w.WriteHeader(http.StatusOK)
+			return enc.begin()
+		}
+
+		count := 0
+		err = db.ExportSongs(r.Context(), filters, func(song models.Song) error {
+			if !started {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+			count++
+			return enc.encode(song)
+		})
+		if err == nil && !started {
+			err = start()
+		}
+		if err == nil {
+			err = enc.end()
+		}
+		if err != nil {
+			if !started {
+				writeError(log, w, r, err)
+				return
+			}
+			log.Error("export interrupted", "exported", count, "error", err)
+			return
+		}
+
+		log.Info("end exporting songs", "format", formatName, "exported", count)
+	}
+}
+
+type csvSongEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvSongEncoder) begin() error {
+	return e.w.Write([]string{"id", "group_name", "song_name", "release_date", "text", "link", "enrichment_status"})
+}
+
+func (e *csvSongEncoder) encode(song models.Song) error {
+	releaseDate := ""
+	if song.ReleaseDate != nil {
+		releaseDate = song.ReleaseDate.Format(dateLayout)
+	}
+	return e.w.Write([]string{
+		strconv.Itoa(song.ID),
+		song.Group,
+		song.Songname,
+		releaseDate,
+		song.Text,
+		song.Link,
+		song.EnrichmentStatus,
+	})
+}
+
+func (e *csvSongEncoder) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonSongEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonSongEncoder) begin() error { return nil }
+
+func (e *ndjsonSongEncoder) encode(song models.Song) error {
+	return e.enc.Encode(song)
+}
+
+func (e *ndjsonSongEncoder) end() error { return nil }
+
+// jsonSongEncoder writes a single JSON array, one song per line.
+type jsonSongEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonSongEncoder) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonSongEncoder) encode(song models.Song) error {
+	data, err := json.Marshal(song)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if e.count == 0 {
+		sep = "\n"
+	}
+	e.count++
+
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonSongEncoder) end() error {
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+const dateLayout = "2006-01-02"
+
+// songListParams are the query parameters GET /songs understands besides the filters.
+var songListParams = []string{"sort", "cursor", "limit"}
+
+// songFilterParams are the query parameters parsed into models.SongFilter.
+var songFilterParams = []string{
+	"group_name", "song_name", "release_date", "released_after", "released_before",
+	"year", "text", "link", "link_contains", "q", "lyrics_query",
+}
+
+// checkParams rejects query parameters that are not in any of the allowed lists.
+func checkParams(query url.Values, allowed ...[]string) error {
+	known := make(map[string]bool)
+	for _, list := range allowed {
+		for _, name := range list {
+			known[name] = true
+		}
+	}
+
+	var unknown []string
+	for name := range query {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return errors.InvalidArgument(fmt.Sprintf("unknown query parameters: %s", strings.Join(unknown, ", ")))
+	}
+	return nil
+}
+
+// parseSongFilter builds a song filter from the query string.
+func parseSongFilter(query url.Values) (models.SongFilter, error) {
+	filters := models.SongFilter{
+		Group:        strings.TrimSpace(query.Get("group_name")),
+		Songname:     strings.TrimSpace(query.Get("song_name")),
+		Text:         query.Get("text"),
+		Link:         query.Get("link"),
+		LinkContains: strings.TrimSpace(query.Get("link_contains")),
+		Query:        query.Get("q"),
+	}
+
+	if filters.Query == "" {
+		filters.Query = query.Get("lyrics_query")
+	}
+
+	dates := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"release_date", &filters.ReleaseDate},
+		{"released_after", &filters.ReleasedAfter},
+		{"released_before", &filters.ReleasedBefore},
+	}
+	for _, d := range dates {
+		value := query.Get(d.name)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return models.SongFilter{}, errors.InvalidArgument(fmt.Sprintf("invalid %s format. Expected YYYY-MM-DD", d.name))
+		}
+		*d.dst = date
+	}
+
+	if !filters.ReleasedAfter.IsZero() && !filters.ReleasedBefore.IsZero() &&
+		filters.ReleasedAfter.After(filters.ReleasedBefore) {
+		return models.SongFilter{}, errors.InvalidArgument("released_after must not be later than released_before")
+	}
+
+	if yearStr := query.Get("year"); yearStr != "" {
+		year, err := strconv.Atoi(yearStr)
+		if err != nil || year < 1 || year > 9999 {
+			return models.SongFilter{}, errors.InvalidArgument("invalid year. Expected a number between 1 and 9999")
+		}
+		filters.Year = year
+	}
+
+	return filters, nil
+}
+
+// parseSort parses a comma-separated list of fields, each optionally prefixed
+// with "-" for descending order. The parameter may also be repeated.
+func parseSort(values []string) ([]models.SortField, error) {
+	var fields []models.SortField
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			field := models.SortField{Field: strings.TrimPrefix(part, "-")}
+			field.Desc = field.Field != part
+			if field.Field == "" {
+				return nil, errors.InvalidArgument(fmt.Sprintf("invalid sort parameter %q", value))
+			}
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
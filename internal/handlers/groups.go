@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/nongrata2/musiclib/internal/logging"
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+func GetGroupsDataHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("getting groups data handler")
+		log.Info("start getting groups")
+
+		groups, err := db.GetGroups(r.Context())
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		if groups == nil {
+			groups = []models.Group{}
+		}
+
+		writeJSON(log, w, r, http.StatusOK, groups)
+		log.Info("end getting groups")
+	}
+}
+
+func GetGroupHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("getting group handler")
+		log.Info("start getting group")
+
+		groupID, err := pathID(r, "groupID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		group, err := db.GetGroup(r.Context(), groupID)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		writeJSON(log, w, r, http.StatusOK, group)
+		log.Info("end getting group")
+	}
+}
+
+func AddGroupHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("adding group handler")
+		log.Info("start adding group")
+
+		name, err := decodeGroupName(r.Body)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		group, err := db.AddGroup(r.Context(), name)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/groups/%d", group.ID))
+		writeJSON(log, w, r, http.StatusCreated, group)
+		log.Info("end adding group")
+	}
+}
+
+func EditGroupHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("editing group handler")
+		log.Info("start editing group")
+
+		groupID, err := pathID(r, "groupID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		name, err := decodeGroupName(r.Body)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		group, err := db.UpdateGroup(r.Context(), groupID, name)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		writeJSON(log, w, r, http.StatusOK, group)
+		log.Info("end editing group")
+	}
+}
+
+// DeleteGroupHandler removes a group. A group that still has songs is only
+// removed with ?cascade=true, which removes its songs as well.
+func DeleteGroupHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("deleting group handler")
+		log.Info("start deleting group")
+
+		groupID, err := pathID(r, "groupID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		cascade, err := parseBool(r, "cascade")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		if err := db.DeleteGroup(r.Context(), groupID, cascade); err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		log.Info("end deleting group", "id", groupID)
+	}
+}
+
+// decodeGroupName reads the group name from the request body.
+func decodeGroupName(body io.Reader) (string, error) {
+	var request models.GroupRequest
+	if err := json.NewDecoder(body).Decode(&request); err != nil {
+		return "", errors.InvalidArgument("invalid request body").Wrap(err)
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return "", errors.InvalidArgument("group_name must not be empty")
+	}
+
+	return name, nil
+}
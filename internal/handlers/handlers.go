@@ -3,295 +3,440 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	stdErrors "errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/nongrata2/musiclib/internal/externalapi"
+	"github.com/nongrata2/musiclib/internal/logging"
 	"github.com/nongrata2/musiclib/internal/models"
 	"github.com/nongrata2/musiclib/pkg/errors"
 )
 
+const (
+	onConflictError  = "error"
+	onConflictUpdate = "update"
+)
+
+// SongInfoProvider looks up song details in the external API.
+type SongInfoProvider interface {
+	GetSongInfo(ctx context.Context, group, song string) (externalapi.APIResponse, error)
+}
+
 type DBInterface interface {
-	Add(ctx context.Context, song models.Song) error
-	GetSongs(ctx context.Context, filters models.SongFilter, page, limit int) ([]models.Song, error)
-	Delete(ctx context.Context, songID string) error
-	GetLyrics(ctx context.Context, songID string, page, limit int) (string, error)
+	Add(ctx context.Context, song models.Song) (*models.Song, error)
+	AddPending(ctx context.Context, song models.Song) (*models.Song, *models.Job, error)
+	ImportSongs(ctx context.Context, songs []models.Song, dryRun bool) ([]models.ImportedSong, error)
+	FindSong(ctx context.Context, group, songName string) (*models.Song, error)
+	GetSongs(ctx context.Context, filters models.SongFilter, page models.PageRequest) (*models.SongPage, error)
+	ExportSongs(ctx context.Context, filters models.SongFilter, fn func(models.Song) error) error
+	Delete(ctx context.Context, songID int) error
+	GetLyrics(ctx context.Context, songID int, page, limit int) (string, error)
 	Update(ctx context.Context, id int, song models.Song) (*models.Song, error)
+	Patch(ctx context.Context, id int, patch models.SongPatch) (*models.Song, error)
+
+	GetGroups(ctx context.Context) ([]models.Group, error)
+	GetGroup(ctx context.Context, id int) (*models.Group, error)
+	AddGroup(ctx context.Context, name string) (*models.Group, error)
+	UpdateGroup(ctx context.Context, id int, name string) (*models.Group, error)
+	DeleteGroup(ctx context.Context, id int, cascade bool) error
+
+	GetJob(ctx context.Context, id int) (*models.Job, error)
+
+	GetPlaylists(ctx context.Context) ([]models.Playlist, error)
+	GetPlaylist(ctx context.Context, id int) (*models.Playlist, error)
+	AddPlaylist(ctx context.Context, name string) (*models.Playlist, error)
+	UpdatePlaylist(ctx context.Context, id int, name string) (*models.Playlist, error)
+	DeletePlaylist(ctx context.Context, id int) error
+	AddPlaylistSong(ctx context.Context, playlistID, songID, position int) error
+	MovePlaylistSong(ctx context.Context, playlistID, songID, position int) error
+	RemovePlaylistSong(ctx context.Context, playlistID, songID int) error
 }
 
-func AddSongHandler(log *slog.Logger, db DBInterface, apiBaseURL string) http.HandlerFunc {
+// Modes of consulting the external API when adding a song.
+const (
+	// enrichAlways takes details from the external API, falling back to the
+	// ones in the request where it has none.
+	enrichAlways = "always"
+	// enrichMissing keeps details given in the request and asks the external
+	// API only for missing ones. A song it does not know is added as given.
+	enrichMissing = "missing"
+	// enrichNever adds the song exactly as given.
+	enrichNever = "never"
+)
+
+// AddSongHandler adds a song, taking its details from the request and the
+// external API as ?enrich says. A song that already exists is reported as a
+// conflict, or refreshed with ?on_conflict=update. If the external API is
+// unavailable, a new song is saved with the details given and 202 Accepted
+// is returned with a job filling in the rest later.
+func AddSongHandler(log *slog.Logger, db DBInterface, songInfo SongInfoProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
 		log.Debug("adding song handler")
 		log.Info("start adding song")
-		var request struct {
-			Group    string `json:"group_name"`
-			Songname string `json:"song_name"`
-		}
+		var request models.SongRequest
 
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			log.Error("failed to decode request body", "error", err)
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(log, w, r, errors.InvalidArgument("invalid request body").Wrap(err))
 			return
 		}
 
-		apiResponse, err := externalapi.GetDataFromExternalAPI(apiBaseURL, request.Group, request.Songname)
+		newSong, err := requestSong(request)
 		if err != nil {
-			log.Error("failed to get data from external API", "error", err)
-			http.Error(w, "Failed to get data from external API", http.StatusInternalServerError)
+			writeError(log, w, r, err)
 			return
 		}
 
-		newSong := models.Song{
-			Group:       request.Group,
-			Songname:    request.Songname,
-			Text:        apiResponse.Text,
-			ReleaseDate: apiResponse.ReleaseDate,
-			Link:        apiResponse.Link,
-		}
-
-		if err := db.Add(r.Context(), newSong); err != nil {
-			log.Error("failed to add song", "error", err)
-			http.Error(w, "Failed to add song", http.StatusInternalServerError)
+		onConflict := r.URL.Query().Get("on_conflict")
+		if onConflict != "" && onConflict != onConflictError && onConflict != onConflictUpdate {
+			writeError(log, w, r, errors.InvalidArgument("on_conflict must be either error or update"))
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
-		_, err = w.Write([]byte("Song was added successfully\n"))
+		enrich, err := parseEnrichMode(r, enrichAlways)
 		if err != nil {
-			log.Error("error writing", "error", err)
+			writeError(log, w, r, err)
+			return
 		}
 
-		log.Info("end adding song")
-	}
-}
+		// look for a duplicate first, so it does not cost an external API call
+		existing, err := db.FindSong(r.Context(), newSong.Group, newSong.Songname)
+		switch {
+		case err == nil && onConflict != onConflictUpdate:
+			writeError(log, w, r, errors.SongExistsErr.WithDetails(map[string]any{"song_id": existing.ID}))
+			return
+		case err != nil && !stdErrors.Is(err, errors.NotFoundErr):
+			writeError(log, w, r, err)
+			return
+		}
 
-func GetLibDataHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		log.Debug("getting library data handler")
-		log.Info("start getting data from library")
+		newSong, err = enrichSong(r.Context(), songInfo, newSong, enrich)
+		if err != nil {
+			if existing != nil || newSong.EnrichmentStatus != models.EnrichmentPending {
+				writeError(log, w, r, err)
+				return
+			}
 
-		filters := models.SongFilter{
-			Group:    r.URL.Query().Get("group_name"),
-			Songname: r.URL.Query().Get("song_name"),
-			Text:     r.URL.Query().Get("text"),
-			Link:     r.URL.Query().Get("link"),
+			log.Warn("external API is unavailable, song will be enriched later", "error", err)
+			addPendingSong(log, w, r, db, newSong)
+			return
 		}
 
-		releaseDateStr := r.URL.Query().Get("release_date")
-		if releaseDateStr != "" {
-			var err error
-			releaseDate, err := time.Parse("2006-01-02", releaseDateStr)
+		if existing != nil {
+			updatedSong, err := db.Update(r.Context(), existing.ID, newSong)
 			if err != nil {
-				log.Error("failed to parse release date", "error", err)
-				http.Error(w, "Invalid release_date format. Expected YYYY-MM-DD", http.StatusBadRequest)
+				writeError(log, w, r, err)
 				return
 			}
-			filters.ReleaseDate = releaseDate
+
+			writeJSON(log, w, r, http.StatusOK, updatedSong)
+
+			log.Info("end adding song", "updated_id", existing.ID)
+			return
 		}
 
-		pagestr := r.URL.Query().Get("page")
-		limitstr := r.URL.Query().Get("limit")
+		addedSong, err := db.Add(r.Context(), newSong)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
 
-		var page, limit int
-		var err error
+		w.Header().Set("Location", fmt.Sprintf("/songs/%d", addedSong.ID))
+		writeJSON(log, w, r, http.StatusCreated, addedSong)
 
-		if pagestr != "" {
-			page, err = strconv.Atoi(pagestr)
-			if err != nil || page < 1 {
-				log.Error("wrong page number", "error", err)
-				http.Error(w, "wrong page number", http.StatusInternalServerError)
-				return
-			}
-		} else {
-			page = 0
+		log.Info("end adding song", "id", addedSong.ID)
+	}
+}
+
+// requestSong validates a request to add a song and turns it into a song
+// that is not enriched yet.
+func requestSong(request models.SongRequest) (models.Song, error) {
+	song := models.Song{
+		Group:            strings.TrimSpace(request.Group),
+		Songname:         strings.TrimSpace(request.Songname),
+		Text:             request.Text,
+		Link:             request.Link,
+		EnrichmentStatus: models.EnrichmentSkipped,
+	}
+	if song.Group == "" || song.Songname == "" {
+		return models.Song{}, errors.InvalidArgument("group_name and song_name must not be empty")
+	}
+
+	if request.ReleaseDate != "" {
+		releaseDate, err := parseDateString(request.ReleaseDate)
+		if err != nil {
+			return models.Song{}, err
 		}
+		song.ReleaseDate = &releaseDate
+	}
 
-		if limitstr != "" {
-			limit, err = strconv.Atoi(limitstr)
-			if err != nil || limit < 1 {
-				log.Error("wrong limit number", "error", err)
-				http.Error(w, "wrong limit number", http.StatusInternalServerError)
-				return
-			}
-		} else {
-			limit = 0
+	return song, nil
+}
+
+// parseEnrichMode reads the ?enrich query parameter.
+func parseEnrichMode(r *http.Request, defaultMode string) (string, error) {
+	switch enrich := r.URL.Query().Get("enrich"); enrich {
+	case "":
+		return defaultMode, nil
+	case enrichAlways, enrichMissing, enrichNever:
+		return enrich, nil
+	default:
+		return "", errors.InvalidArgument("enrich must be one of always, missing, never")
+	}
+}
+
+// enrichSong fills in details of the song from the external API as the
+// enrich mode says. If the lookup failed in a way that is likely to pass,
+// the song is returned marked pending together with the error, so that it
+// can be saved and enriched later; asking again after any other failure
+// would not help.
+func enrichSong(ctx context.Context, songInfo SongInfoProvider, song models.Song, enrich string) (models.Song, error) {
+	if enrich == enrichNever || enrich == enrichMissing && !missingDetails(song) {
+		return song, nil
+	}
+
+	apiResponse, err := songInfo.GetSongInfo(ctx, song.Group, song.Songname)
+	switch {
+	case err == nil:
+		song = withSongInfo(song, apiResponse, enrich == enrichAlways)
+		song.EnrichmentStatus = models.EnrichmentSucceeded
+	case enrich == enrichMissing && stdErrors.Is(err, externalapi.ErrNotFound):
+		// a song unknown to the external API is added as given
+		song.EnrichmentStatus = models.EnrichmentFailed
+	default:
+		if externalapi.IsTransient(err) {
+			song.EnrichmentStatus = models.EnrichmentPending
 		}
+		return song, externalAPIError(err)
+	}
+
+	return song, nil
+}
+
+// missingDetails reports whether any detail of the song is unknown.
+func missingDetails(song models.Song) bool {
+	return song.ReleaseDate == nil || song.Text == "" || song.Link == ""
+}
+
+// withSongInfo fills in details of the song from the external API. With
+// override its details replace the ones already set, otherwise only the
+// missing ones are filled in.
+func withSongInfo(song models.Song, info externalapi.APIResponse, override bool) models.Song {
+	if !info.ReleaseDate.IsZero() && (override || song.ReleaseDate == nil) {
+		song.ReleaseDate = &info.ReleaseDate
+	}
+	if info.Text != "" && (override || song.Text == "") {
+		song.Text = info.Text
+	}
+	if info.Link != "" && (override || song.Link == "") {
+		song.Link = info.Link
+	}
+	return song
+}
+
+// GetLibDataHandler returns a page of songs. The page size defaults to
+// defaultLimit and is capped at maxLimit.
+func GetLibDataHandler(log *slog.Logger, db DBInterface, defaultLimit, maxLimit int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("getting library data handler")
+		log.Info("start getting data from library")
 
-		if limit == 0 && page != 0 || page == 0 && limit != 0 {
-			log.Warn("not using pagynation. both limit and page parameters should be filled")
-			limit, page = 0, 0
+		if err := checkParams(r.URL.Query(), songFilterParams, songListParams); err != nil {
+			writeError(log, w, r, err)
+			return
 		}
 
-		songs, err := db.GetSongs(r.Context(), filters, page, limit)
+		filters, err := parseSongFilter(r.URL.Query())
 		if err != nil {
-			log.Error("failed to fetch songs", "error", err)
-			http.Error(w, "Failed to fetch songs", http.StatusInternalServerError)
+			writeError(log, w, r, err)
 			return
 		}
 
-		if len(songs) == 0 {
-			_, err := w.Write([]byte("No songs was found\n"))
-			if err != nil {
-				log.Error("error writing", "error", err)
-			}
-		} else {
-			w.Header().Set("Content-Type", "application/json")
+		sort, err := parseSort(r.URL.Query()["sort"])
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
 
-			jsonData, err := json.MarshalIndent(songs, "", "  ")
-			if err != nil {
-				log.Error("failed to encode songs to JSON", "error", err)
-				http.Error(w, "Failed to encode songs", http.StatusInternalServerError)
+		limit := defaultLimit
+		if limitstr := r.URL.Query().Get("limit"); limitstr != "" {
+			limit, err = strconv.Atoi(limitstr)
+			if err != nil || limit < 1 || limit > maxLimit {
+				writeError(log, w, r, errors.InvalidArgument(fmt.Sprintf("limit must be a number between 1 and %d", maxLimit)))
 				return
 			}
+		}
 
-			_, err = w.Write(jsonData)
-			if err != nil {
-				log.Error("error writing", "error", err)
-			}
+		pageRequest := models.PageRequest{
+			Sort:   sort,
+			Cursor: r.URL.Query().Get("cursor"),
+			Limit:  limit,
+		}
+
+		page, err := db.GetSongs(r.Context(), filters, pageRequest)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
 		}
+
+		writeJSON(log, w, r, http.StatusOK, page)
 		log.Info("end getting data from library")
 	}
 }
 
 func DeleteSongHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
 		log.Debug("deleting song handler")
 		log.Info("start deleting song")
-		songID := r.PathValue("songID")
+		songID, err := pathID(r, "songID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
 
 		if err := db.Delete(r.Context(), songID); err != nil {
-			log.Error("failed to delete song", "error", err)
-			http.Error(w, "Failed to delete song", http.StatusInternalServerError)
+			writeError(log, w, r, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		outstr := fmt.Sprintf("song with id %v was deleted successfully\n", songID)
-		_, err := w.Write([]byte(outstr))
-		if err != nil {
-			log.Error("error writing", "error", err)
-		}
+		// deletions have always been answered with 200, clients may rely on it
+		writeJSON(log, w, r, http.StatusOK, models.DeletedSong{
+			ID:      songID,
+			Message: fmt.Sprintf("song with id %v was deleted successfully", songID),
+		})
 
-		log.Info("end deleting song")
+		log.Info("end deleting song", "id", songID)
 	}
 }
 
 func GetLyricsHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
 		log.Debug("getting lyrics handler")
 		log.Info("start getting lyrics")
 
-		songID := r.PathValue("songID")
-		pagestr := r.URL.Query().Get("page")
-		limitstr := r.URL.Query().Get("limit")
-
-		var page, limit int
-		var err error
-
-		if pagestr != "" {
-			page, err = strconv.Atoi(pagestr)
-			if err != nil || page < 1 {
-				log.Error("wrong page number", "error", err)
-				http.Error(w, "wrong page number", http.StatusInternalServerError)
-				return
-			}
-		} else {
-			page = 0
-		}
-
-		if limitstr != "" {
-			limit, err = strconv.Atoi(limitstr)
-			if err != nil || limit < 1 {
-				log.Error("wrong limit number", "error", err)
-				http.Error(w, "wrong limit number", http.StatusInternalServerError)
-				return
-			}
-		} else {
-			limit = 0
+		songID, err := pathID(r, "songID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
 		}
 
-		if limit == 0 && page != 0 || page == 0 && limit != 0 {
-			log.Warn("not using pagynation. both limit and page parameters should be filled")
-			limit, page = 0, 0
+		page, limit, err := parsePageLimit(r)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
 		}
 
 		songLyrics, err := db.GetLyrics(r.Context(), songID, page, limit)
 		if err != nil {
-			if err == errors.NotFoundErr {
-				log.Error("no song with the given ID", "error", err)
-				http.Error(w, "no song with the given ID", http.StatusNotFound)
-				return
-			}
-			log.Error("failed to get lyrics of the song", "error", err)
-			http.Error(w, "Failed to get lyrics of the song", http.StatusInternalServerError)
+			writeError(log, w, r, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		_, err = w.Write([]byte(songLyrics))
-		if err != nil {
-			log.Error("error writing", "error", err)
-		}
+		writeJSON(log, w, r, http.StatusOK, models.Lyrics{
+			SongID: songID,
+			Text:   songLyrics,
+			Page:   page,
+			Limit:  limit,
+		})
 		log.Info("end getting lyrics")
 	}
 }
 
 func EditSongHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
 		log.Debug("editing song handler")
 		log.Info("start editing song")
 
-		songIDstr := r.PathValue("songID")
-
-		songID, err := strconv.Atoi(songIDstr)
+		songID, err := pathID(r, "songID")
 		if err != nil {
-			log.Error("invalid song ID", "error", err)
-			http.Error(w, "Invalid song ID", http.StatusBadRequest)
+			writeError(log, w, r, err)
 			return
 		}
 
-		var song models.Song
-		if err := json.NewDecoder(r.Body).Decode(&song); err != nil {
-			log.Error("failed to decode request body", "error", err)
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		// PUT replaces the whole song, so every field has to be supplied
+		patch, err := parseSongPatch(r.Body)
+		if err != nil {
+			writeError(log, w, r, err)
 			return
 		}
 
-		updatedSong, err := db.Update(r.Context(), songID, song)
+		song, err := fullSong(patch)
 		if err != nil {
-			if err == errors.NotFoundErr {
-				log.Error("no song with the given ID", "error", err)
-				http.Error(w, "no song with the given ID", http.StatusNotFound)
-				return
-			}
-			log.Error("failed to update song", "error", err)
-			http.Error(w, "Failed to update song", http.StatusInternalServerError)
+			writeError(log, w, r, err)
 			return
 		}
 
-		_, err = w.Write([]byte("song was updated successfully\n"))
+		updatedSong, err := db.Update(r.Context(), songID, song)
 		if err != nil {
-			log.Error("error writing", "error", err)
+			writeError(log, w, r, err)
+			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		writeJSON(log, w, r, http.StatusOK, updatedSong)
 
-		w.Header().Set("Content-Type", "application/json")
+		log.Info("end editing song")
+	}
+}
 
-		jsonData, err := json.MarshalIndent(updatedSong, "", "  ")
-		if err != nil {
-			log.Error("failed to encode song to JSON", "error", err)
-			http.Error(w, "Failed to encode song", http.StatusInternalServerError)
-			return
+// externalAPIError describes a failed external API lookup for the client:
+// a failure of the external API is a bad gateway, while the circuit breaker
+// being open means the service itself does not ask it for now.
+func externalAPIError(err error) error {
+	switch {
+	case stdErrors.Is(err, externalapi.ErrNotFound):
+		return errors.NotFound("song not found in external API").Wrap(err)
+	case stdErrors.Is(err, context.Canceled):
+		return err
+	case stdErrors.Is(err, externalapi.ErrCircuitOpen):
+		return errors.Unavailable("external API is temporarily unavailable").Wrap(err)
+	case externalapi.IsTransient(err):
+		return errors.BadGateway("failed to get data from external API").Wrap(err)
+	case stdErrors.Is(err, context.DeadlineExceeded):
+		return err
+	default:
+		return errors.BadGateway("external API returned an unexpected response").Wrap(err)
+	}
+}
+
+// pathID parses a numeric identifier from the request path.
+func pathID(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil || id < 1 {
+		return 0, errors.InvalidArgument(fmt.Sprintf("invalid %s", name))
+	}
+	return id, nil
+}
+
+// parsePageLimit reads the optional page and limit query parameters.
+// Pagination is applied only when both are given.
+func parsePageLimit(r *http.Request) (page, limit int, err error) {
+	pagestr := r.URL.Query().Get("page")
+	limitstr := r.URL.Query().Get("limit")
+
+	if pagestr != "" {
+		page, err = strconv.Atoi(pagestr)
+		if err != nil || page < 1 {
+			return 0, 0, errors.InvalidArgument("wrong page number")
 		}
-		_, err = w.Write(jsonData)
-		if err != nil {
-			log.Error("error writing", "error", err)
+	}
+
+	if limitstr != "" {
+		limit, err = strconv.Atoi(limitstr)
+		if err != nil || limit < 1 {
+			return 0, 0, errors.InvalidArgument("wrong limit number")
 		}
+	}
 
-		log.Info("end editing song")
+	if page == 0 || limit == 0 {
+		return 0, 0, nil
 	}
+	return page, limit, nil
 }
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nongrata2/musiclib/internal/logging"
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+const (
+	csvContentType    = "text/csv"
+	ndjsonContentType = "application/x-ndjson"
+
+	// maxImportLineSize limits one NDJSON line, lyrics included.
+	maxImportLineSize = 1 << 20
+)
+
+// importLine is a song read from a line of an imported list.
+type importLine struct {
+	line    int
+	request models.SongRequest
+	err     error
+}
+
+// ImportSongsHandler adds songs listed in a CSV or NDJSON body in a single
+// transaction and reports the outcome of every line. Lines that fail
+// validation or are rejected by the database are skipped, the rest are
+// still imported. With ?dry_run=true
+// the list is only checked and nothing is written; the external API is not
+// consulted then either. The request counts once against the rate limit, so
+// lists enriched from the external API are limited to maxEnrichRows.
+func ImportSongsHandler(log *slog.Logger, db DBInterface, songInfo SongInfoProvider, maxRows, maxEnrichRows int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("importing songs handler")
+		log.Info("start importing songs")
+
+		dryRun, err := parseBool(r, "dry_run")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		// importing thousands of songs should not cost as many lookups by default
+		enrich, err := parseEnrichMode(r, enrichNever)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		var lines []importLine
+		switch mediaType {
+		case csvContentType:
+			lines, err = readCSVSongs(r.Body, maxRows)
+		case ndjsonContentType, "application/jsonl":
+			lines, err = readNDJSONSongs(r.Body, maxRows)
+		default:
+			err = errors.New(errors.CodeUnsupportedMediaType, http.StatusUnsupportedMediaType,
+				"Content-Type must be either "+csvContentType+" or "+ndjsonContentType)
+		}
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+		if enrich != enrichNever && !dryRun && len(lines) > maxEnrichRows {
+			writeError(log, w, r, errors.InvalidArgument(fmt.Sprintf(
+				"at most %d songs can be imported at once with enrich=%s", maxEnrichRows, enrich)))
+			return
+		}
+
+		report := models.ImportReport{
+			DryRun: dryRun,
+			Rows:   make([]models.ImportRow, len(lines)),
+		}
+
+		var songs []models.Song
+		var rows []int
+		for i, line := range lines {
+			report.Rows[i] = models.ImportRow{Line: line.line}
+
+			song, err := importSong(r, songInfo, line, enrich, dryRun)
+			if err != nil {
+				report.Rows[i].Status = models.ImportError
+				report.Rows[i].Error = errors.From(err).Message
+				report.Errors++
+				continue
+			}
+
+			songs = append(songs, song)
+			rows = append(rows, i)
+		}
+
+		if len(songs) > 0 {
+			results, err := db.ImportSongs(r.Context(), songs, dryRun)
+			if err != nil {
+				writeError(log, w, r, err)
+				return
+			}
+
+			for j, result := range results {
+				row := &report.Rows[rows[j]]
+				switch {
+				case result.Err != nil:
+					row.Status = models.ImportError
+					row.Error = errors.From(result.Err).Message
+					report.Errors++
+				case result.Duplicate:
+					row.Status = models.ImportDuplicate
+					report.Duplicates++
+				default:
+					row.Status = models.ImportCreated
+					if !dryRun {
+						row.SongID = result.ID
+					}
+					report.Created++
+				}
+			}
+		}
+
+		writeJSON(log, w, r, http.StatusOK, report)
+
+		log.Info("end importing songs", "created", report.Created, "duplicates", report.Duplicates,
+			"errors", report.Errors, "dry_run", dryRun)
+	}
+}
+
+// importSong validates an imported line and enriches its song. A song the
+// external API cannot be asked about now is imported pending.
+func importSong(r *http.Request, songInfo SongInfoProvider, line importLine, enrich string, dryRun bool) (models.Song, error) {
+	if line.err != nil {
+		return models.Song{}, line.err
+	}
+
+	song, err := requestSong(line.request)
+	if err != nil || dryRun {
+		return song, err
+	}
+
+	song, err = enrichSong(r.Context(), songInfo, song, enrich)
+	if err != nil && song.EnrichmentStatus != models.EnrichmentPending {
+		return models.Song{}, err
+	}
+
+	return song, nil
+}
+
+// readCSVSongs reads songs from CSV with a header naming the columns.
+// group_name and song_name are required, release_date, text and link are optional.
+func readCSVSongs(body io.Reader, maxRows int) ([]importLine, error) {
+	reader := csv.NewReader(body)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.InvalidArgument("failed to read CSV header").Wrap(err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(songFields, name) {
+			return nil, errors.InvalidArgument(fmt.Sprintf("unknown CSV column %q", name))
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"group_name", "song_name"} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.InvalidArgument(fmt.Sprintf("CSV column %q is required", name))
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	var lines []importLine
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(lines) >= maxRows {
+			return nil, tooManyRows(maxRows)
+		}
+
+		var parseErr *csv.ParseError
+		if stdErrors.As(err, &parseErr) {
+			lines = append(lines, importLine{
+				line: parseErr.StartLine,
+				err:  errors.InvalidArgument(parseErr.Err.Error()),
+			})
+			continue
+		}
+		if err != nil {
+			return nil, errors.InvalidArgument("failed to read CSV").Wrap(err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		lines = append(lines, importLine{
+			line: line,
+			request: models.SongRequest{
+				Group:       field(record, "group_name"),
+				Songname:    field(record, "song_name"),
+				ReleaseDate: field(record, "release_date"),
+				Text:        field(record, "text"),
+				Link:        field(record, "link"),
+			},
+		})
+	}
+
+	return lines, nil
+}
+
+// readNDJSONSongs reads songs from newline-delimited JSON, one object per
+// line. Blank lines are skipped.
+func readNDJSONSongs(body io.Reader, maxRows int) ([]importLine, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+
+	var lines []importLine
+	for n := 1; scanner.Scan(); n++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if len(lines) >= maxRows {
+			return nil, tooManyRows(maxRows)
+		}
+
+		line := importLine{line: n}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&line.request); err != nil {
+			line.err = errors.InvalidArgument("invalid JSON: " + err.Error())
+		}
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.InvalidArgument("failed to read NDJSON").Wrap(err)
+	}
+
+	return lines, nil
+}
+
+func tooManyRows(maxRows int) error {
+	return errors.InvalidArgument(fmt.Sprintf("too many songs, at most %d can be imported at once", maxRows))
+}
+
+// parseBool reads an optional boolean query parameter.
+func parseBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.InvalidArgument(fmt.Sprintf("%s must be true or false", name))
+	}
+	return b, nil
+}
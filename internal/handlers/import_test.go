@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nongrata2/musiclib/internal/externalapi"
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+// importDB imports songs in memory. The songs named in outcomes get those,
+// the rest are added with ids counting from 100.
+type importDB struct {
+	DBInterface
+	outcomes map[string]models.ImportedSong
+	songs    []models.Song
+	dryRun   bool
+}
+
+func (db *importDB) ImportSongs(_ context.Context, songs []models.Song, dryRun bool) ([]models.ImportedSong, error) {
+	db.songs, db.dryRun = songs, dryRun
+
+	results := make([]models.ImportedSong, len(songs))
+	for i, song := range songs {
+		result, ok := db.outcomes[song.Songname]
+		if !ok && !dryRun {
+			result.ID = 100 + i
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// songInfoFunc looks up songs with a function.
+type songInfoFunc func(group, song string) (externalapi.APIResponse, error)
+
+func (f songInfoFunc) GetSongInfo(_ context.Context, group, song string) (externalapi.APIResponse, error) {
+	return f(group, song)
+}
+
+func TestImportSongsHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	const csvList = "group_name,song_name,release_date\n" +
+		"Muse,Hysteria,2003-12-01\n" +
+		"Muse,,2003-12-01\n" +
+		"Muse,Uprising,\n" +
+		"Muse,Starlight,yesterday\n" +
+		"Muse,Madness,2012-08-20\n"
+
+	// outcomes of the database for csvList
+	outcomes := map[string]models.ImportedSong{
+		"Uprising": {Duplicate: true},
+		"Madness":  {Err: errors.Conflict("rejected")},
+	}
+
+	var lookups int
+	found := songInfoFunc(func(string, string) (externalapi.APIResponse, error) {
+		lookups++
+		return externalapi.APIResponse{Text: "lyrics", Link: "https://example.com"}, nil
+	})
+	unavailable := songInfoFunc(func(string, string) (externalapi.APIResponse, error) {
+		lookups++
+		return externalapi.APIResponse{}, externalapi.ErrCircuitOpen
+	})
+	broken := songInfoFunc(func(string, string) (externalapi.APIResponse, error) {
+		lookups++
+		return externalapi.APIResponse{}, stdErrors.New("unexpected response")
+	})
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		songInfo    SongInfoProvider
+		status      int
+		code        errors.Code
+		report      models.ImportReport
+		// imported are the enrichment statuses of the songs sent to the database
+		imported []string
+		lookups  int
+	}{
+		{
+			name:        "CSV",
+			contentType: "text/csv; charset=utf-8",
+			body:        csvList,
+			songInfo:    found,
+			status:      http.StatusOK,
+			report: models.ImportReport{Created: 1, Duplicates: 1, Errors: 3, Rows: []models.ImportRow{
+				{Line: 2, Status: models.ImportCreated, SongID: 100},
+				{Line: 3, Status: models.ImportError},
+				{Line: 4, Status: models.ImportDuplicate},
+				{Line: 5, Status: models.ImportError},
+				{Line: 6, Status: models.ImportError},
+			}},
+			imported: []string{models.EnrichmentSkipped, models.EnrichmentSkipped, models.EnrichmentSkipped},
+		},
+		{
+			name:        "dry run",
+			query:       "?dry_run=true&enrich=always",
+			contentType: csvContentType,
+			body:        csvList,
+			songInfo:    found,
+			status:      http.StatusOK,
+			report: models.ImportReport{DryRun: true, Created: 1, Duplicates: 1, Errors: 3, Rows: []models.ImportRow{
+				{Line: 2, Status: models.ImportCreated},
+				{Line: 3, Status: models.ImportError},
+				{Line: 4, Status: models.ImportDuplicate},
+				{Line: 5, Status: models.ImportError},
+				{Line: 6, Status: models.ImportError},
+			}},
+			imported: []string{models.EnrichmentSkipped, models.EnrichmentSkipped, models.EnrichmentSkipped},
+		},
+		{
+			name:        "NDJSON",
+			contentType: ndjsonContentType,
+			body: `{"group_name": "Muse", "song_name": "Hysteria"}` + "\n\n" +
+				`{"group_name": "Muse", "song_name": "Uprising"}` + "\n" +
+				`{"group_name": "Muse", "song": "Starlight"}` + "\n" +
+				`{"group_name": "Muse"` + "\n",
+			songInfo: found,
+			status:   http.StatusOK,
+			report: models.ImportReport{Created: 1, Duplicates: 1, Errors: 2, Rows: []models.ImportRow{
+				{Line: 1, Status: models.ImportCreated, SongID: 100},
+				{Line: 3, Status: models.ImportDuplicate},
+				{Line: 4, Status: models.ImportError},
+				{Line: 5, Status: models.ImportError},
+			}},
+			imported: []string{models.EnrichmentSkipped, models.EnrichmentSkipped},
+		},
+		{
+			name:        "enriched",
+			query:       "?enrich=missing",
+			contentType: csvContentType,
+			body:        "group_name,song_name\nMuse,Hysteria\n",
+			songInfo:    found,
+			status:      http.StatusOK,
+			report: models.ImportReport{Created: 1, Rows: []models.ImportRow{
+				{Line: 2, Status: models.ImportCreated, SongID: 100},
+			}},
+			imported: []string{models.EnrichmentSucceeded},
+			lookups:  1,
+		},
+		{
+			name:        "external API unavailable",
+			query:       "?enrich=always",
+			contentType: csvContentType,
+			body:        "group_name,song_name\nMuse,Hysteria\n",
+			songInfo:    unavailable,
+			status:      http.StatusOK,
+			report: models.ImportReport{Created: 1, Rows: []models.ImportRow{
+				{Line: 2, Status: models.ImportCreated, SongID: 100},
+			}},
+			imported: []string{models.EnrichmentPending},
+			lookups:  1,
+		},
+		{
+			name:        "external API failed",
+			query:       "?enrich=always",
+			contentType: csvContentType,
+			body:        "group_name,song_name\nMuse,Hysteria\n",
+			songInfo:    broken,
+			status:      http.StatusOK,
+			report: models.ImportReport{Errors: 1, Rows: []models.ImportRow{
+				{Line: 2, Status: models.ImportError},
+			}},
+			lookups: 1,
+		},
+		{
+			name:        "too many songs to enrich",
+			query:       "?enrich=always",
+			contentType: csvContentType,
+			body:        "group_name,song_name\nMuse,Hysteria\nMuse,Uprising\nMuse,Starlight\n",
+			songInfo:    found,
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "too many songs",
+			contentType: csvContentType,
+			body:        "group_name,song_name\n" + strings.Repeat("Muse,Hysteria\n", 6),
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "unknown CSV column",
+			contentType: csvContentType,
+			body:        "group_name,song_name,album\nMuse,Hysteria,Absolution\n",
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "missing CSV column",
+			contentType: csvContentType,
+			body:        "group_name\nMuse\n",
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "invalid enrich mode",
+			query:       "?enrich=sometimes",
+			contentType: csvContentType,
+			body:        csvList,
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "application/json",
+			body:        `[{"group_name": "Muse", "song_name": "Hysteria"}]`,
+			status:      http.StatusUnsupportedMediaType,
+			code:        errors.CodeUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &importDB{outcomes: outcomes}
+			lookups = 0
+
+			r := httptest.NewRequest(http.MethodPost, "/songs:import"+tt.query, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			ImportSongsHandler(log, db, tt.songInfo, 5, 2).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.code != "" {
+				var p problem
+				if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+					t.Fatalf("invalid problem document: %v", err)
+				}
+				if p.Code != tt.code {
+					t.Errorf("code %q, want %q", p.Code, tt.code)
+				}
+				if db.songs != nil {
+					t.Errorf("%d songs imported, want none", len(db.songs))
+				}
+				return
+			}
+
+			var report models.ImportReport
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatalf("invalid report: %v", err)
+			}
+			for i, row := range report.Rows {
+				if (row.Status == models.ImportError) != (row.Error != "") {
+					t.Errorf("row %d: status %s with error %q", i, row.Status, row.Error)
+				}
+				report.Rows[i].Error = ""
+			}
+			if !reflect.DeepEqual(report, tt.report) {
+				t.Errorf("report %+v, want %+v", report, tt.report)
+			}
+
+			var imported []string
+			for _, song := range db.songs {
+				imported = append(imported, song.EnrichmentStatus)
+			}
+			if !reflect.DeepEqual(imported, tt.imported) {
+				t.Errorf("imported songs %v, want %v", imported, tt.imported)
+			}
+			if db.dryRun != tt.report.DryRun {
+				t.Errorf("dry run %v, want %v", db.dryRun, tt.report.DryRun)
+			}
+			if lookups != tt.lookups {
+				t.Errorf("%d external API lookups, want %d", lookups, tt.lookups)
+			}
+		})
+	}
+}
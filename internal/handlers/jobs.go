@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/nongrata2/musiclib/internal/logging"
+	"github.com/nongrata2/musiclib/internal/models"
+)
+
+// pendingSong is the response to a song accepted for later enrichment.
+type pendingSong struct {
+	SongID int    `json:"song_id"`
+	JobID  int    `json:"job_id"`
+	Status string `json:"status"`
+}
+
+// addPendingSong saves a song without details and responds with the job
+// that fills them in.
+func addPendingSong(log *slog.Logger, w http.ResponseWriter, r *http.Request, db DBInterface, song models.Song) {
+	addedSong, job, err := db.AddPending(r.Context(), song)
+	if err != nil {
+		writeError(log, w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.ID))
+	writeJSON(log, w, r, http.StatusAccepted, pendingSong{
+		SongID: addedSong.ID,
+		JobID:  job.ID,
+		Status: job.Status,
+	})
+
+	log.Info("end adding song", "song_id", addedSong.ID, "job_id", job.ID)
+}
+
+// GetJobHandler reports the state of a background enrichment job.
+func GetJobHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("getting job handler")
+		log.Info("start getting job")
+
+		jobID, err := pathID(r, "jobID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		job, err := db.GetJob(r.Context(), jobID)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		writeJSON(log, w, r, http.StatusOK, job)
+
+		log.Info("end getting job")
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nongrata2/musiclib/internal/logging"
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// songFields are the fields of a song a client may set.
+var songFields = []string{"group_name", "song_name", "release_date", "text", "link"}
+
+var errInvalidReleaseDate = errors.InvalidArgument("invalid release_date format. Expected YYYY-MM-DD")
+
+// parseSongPatch reads a JSON Merge Patch (RFC 7396) document for a song.
+// Removing text or link with null clears them and removing release_date
+// leaves the song without one. group_name and song_name are required and
+// cannot be removed.
+func parseSongPatch(body io.Reader) (models.SongPatch, error) {
+	// a patch that is not an object would replace the whole song, null
+	// included, which a song cannot be
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&doc); err != nil || doc == nil {
+		return models.SongPatch{}, errors.InvalidArgument("request body must be a JSON object")
+	}
+
+	var patch models.SongPatch
+	for name, raw := range doc {
+		isNull := string(raw) == "null"
+
+		switch name {
+		case "group_name", "song_name":
+			if isNull {
+				return models.SongPatch{}, errors.InvalidArgument(fmt.Sprintf("%s cannot be removed", name))
+			}
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return models.SongPatch{}, errors.InvalidArgument(fmt.Sprintf("%s must be a string", name))
+			}
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return models.SongPatch{}, errors.InvalidArgument(fmt.Sprintf("%s must not be empty", name))
+			}
+			if name == "group_name" {
+				patch.Group = &value
+			} else {
+				patch.Songname = &value
+			}
+		case "release_date":
+			if isNull {
+				patch.ReleaseDate = &time.Time{}
+				continue
+			}
+			date, err := parseDate(raw)
+			if err != nil {
+				return models.SongPatch{}, err
+			}
+			patch.ReleaseDate = &date
+		case "text", "link":
+			var value string
+			if !isNull {
+				if err := json.Unmarshal(raw, &value); err != nil {
+					return models.SongPatch{}, errors.InvalidArgument(fmt.Sprintf("%s must be a string", name))
+				}
+			}
+			if name == "text" {
+				patch.Text = &value
+			} else {
+				patch.Link = &value
+			}
+		default:
+			return models.SongPatch{}, errors.InvalidArgument(fmt.Sprintf("unknown field %q", name))
+		}
+	}
+
+	return patch, nil
+}
+
+// parseDate accepts both a plain YYYY-MM-DD date and an RFC 3339 timestamp.
+func parseDate(raw json.RawMessage) (time.Time, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return time.Time{}, errInvalidReleaseDate
+	}
+	return parseDateString(value)
+}
+
+func parseDateString(value string) (time.Time, error) {
+	if date, err := time.Parse(dateLayout, value); err == nil {
+		return date, nil
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	return time.Time{}, errInvalidReleaseDate
+}
+
+// fullSong turns a patch into a complete song, failing if any field is missing.
+func fullSong(patch models.SongPatch) (models.Song, error) {
+	present := map[string]bool{
+		"group_name":   patch.Group != nil,
+		"song_name":    patch.Songname != nil,
+		"release_date": patch.ReleaseDate != nil,
+		"text":         patch.Text != nil,
+		"link":         patch.Link != nil,
+	}
+
+	var missing []string
+	for _, name := range songFields {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return models.Song{}, errors.InvalidArgument("missing required fields").
+			WithDetails(map[string]any{"missing": missing})
+	}
+
+	song := models.Song{
+		Group:       *patch.Group,
+		Songname:    *patch.Songname,
+		ReleaseDate: patch.ReleaseDate,
+		Text:        *patch.Text,
+		Link:        *patch.Link,
+	}
+	if song.ReleaseDate.IsZero() {
+		song.ReleaseDate = nil
+	}
+	return song, nil
+}
+
+func PatchSongHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("patching song handler")
+		log.Info("start patching song")
+
+		songID, err := pathID(r, "songID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			mediaType, _, err := mime.ParseMediaType(contentType)
+			if err != nil || mediaType != mergePatchContentType && mediaType != "application/json" {
+				w.Header().Set("Accept-Patch", mergePatchContentType)
+				writeError(log, w, r, errors.New(errors.CodeUnsupportedMediaType, http.StatusUnsupportedMediaType,
+					"Content-Type must be "+mergePatchContentType))
+				return
+			}
+		}
+
+		patch, err := parseSongPatch(r.Body)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		patchedSong, err := db.Patch(r.Context(), songID, patch)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		writeJSON(log, w, r, http.StatusOK, patchedSong)
+		log.Info("end patching song")
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+// patchDB records the patch it is given. Other methods are not expected
+// to be called.
+type patchDB struct {
+	DBInterface
+	patch *models.SongPatch
+	err   error
+}
+
+func (db *patchDB) Patch(_ context.Context, id int, patch models.SongPatch) (*models.Song, error) {
+	db.patch = &patch
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &models.Song{ID: id, Group: "Muse", Songname: "Hysteria"}, nil
+}
+
+func TestPatchSongHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	str := func(s string) *string { return &s }
+	date := time.Date(2003, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		songID      string
+		contentType string
+		body        string
+		dbErr       error
+		status      int
+		code        errors.Code
+		// patch is what the database gets, nil if it is not called
+		patch *models.SongPatch
+	}{
+		{
+			name:        "merge patch",
+			contentType: mergePatchContentType,
+			body:        `{"song_name": " Hysteria ", "release_date": "2003-12-01", "text": null}`,
+			status:      http.StatusOK,
+			patch:       &models.SongPatch{Songname: str("Hysteria"), ReleaseDate: &date, Text: str("")},
+		},
+		{
+			name:        "release date removed",
+			contentType: mergePatchContentType,
+			body:        `{"release_date": null}`,
+			status:      http.StatusOK,
+			patch:       &models.SongPatch{ReleaseDate: &time.Time{}},
+		},
+		{
+			name:        "plain JSON",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"link": "https://example.com"}`,
+			status:      http.StatusOK,
+			patch:       &models.SongPatch{Link: str("https://example.com")},
+		},
+		{
+			name:   "no content type",
+			body:   `{"group_name": "Muse"}`,
+			status: http.StatusOK,
+			patch:  &models.SongPatch{Group: str("Muse")},
+		},
+		{
+			name:        "empty patch",
+			contentType: mergePatchContentType,
+			body:        `{}`,
+			status:      http.StatusOK,
+			patch:       &models.SongPatch{},
+		},
+		{
+			name:        "null",
+			contentType: mergePatchContentType,
+			body:        `null`,
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "array",
+			contentType: mergePatchContentType,
+			body:        `[{"song_name": "Hysteria"}]`,
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "empty body",
+			contentType: mergePatchContentType,
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "required field removed",
+			contentType: mergePatchContentType,
+			body:        `{"group_name": null}`,
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "blank name",
+			contentType: mergePatchContentType,
+			body:        `{"song_name": "  "}`,
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "invalid date",
+			contentType: mergePatchContentType,
+			body:        `{"release_date": "01.12.2003"}`,
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "unknown field",
+			contentType: mergePatchContentType,
+			body:        `{"id": 8}`,
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/plain",
+			body:        `{"song_name": "Hysteria"}`,
+			status:      http.StatusUnsupportedMediaType,
+			code:        errors.CodeUnsupportedMediaType,
+		},
+		{
+			name:        "invalid song id",
+			songID:      "zero",
+			contentType: mergePatchContentType,
+			body:        `{"song_name": "Hysteria"}`,
+			status:      http.StatusBadRequest,
+			code:        errors.CodeInvalidArgument,
+		},
+		{
+			name:        "song not found",
+			contentType: mergePatchContentType,
+			body:        `{"song_name": "Hysteria"}`,
+			dbErr:       errors.NotFoundErr,
+			status:      http.StatusNotFound,
+			code:        errors.CodeNotFound,
+			patch:       &models.SongPatch{Songname: str("Hysteria")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &patchDB{err: tt.dbErr}
+			songID := tt.songID
+			if songID == "" {
+				songID = "7"
+			}
+
+			r := httptest.NewRequest(http.MethodPatch, "/songs/"+songID, strings.NewReader(tt.body))
+			r.SetPathValue("songID", songID)
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			PatchSongHandler(log, db).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if !reflect.DeepEqual(db.patch, tt.patch) {
+				t.Errorf("patch %+v, want %+v", db.patch, tt.patch)
+			}
+
+			if tt.code == "" {
+				return
+			}
+			var p problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("invalid problem document: %v", err)
+			}
+			if p.Code != tt.code {
+				t.Errorf("code %q, want %q", p.Code, tt.code)
+			}
+			accept := w.Header().Get("Accept-Patch")
+			if wantAccept := tt.status == http.StatusUnsupportedMediaType; wantAccept != (accept == mergePatchContentType) {
+				t.Errorf("Accept-Patch = %q", accept)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/nongrata2/musiclib/internal/logging"
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+func GetPlaylistsHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("getting playlists handler")
+		log.Info("start getting playlists")
+
+		playlists, err := db.GetPlaylists(r.Context())
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		writeJSON(log, w, r, http.StatusOK, playlists)
+		log.Info("end getting playlists")
+	}
+}
+
+// GetPlaylistHandler returns the playlist with its songs in playlist order.
+func GetPlaylistHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("getting playlist handler")
+		log.Info("start getting playlist")
+
+		playlistID, err := pathID(r, "playlistID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		playlist, err := db.GetPlaylist(r.Context(), playlistID)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		writeJSON(log, w, r, http.StatusOK, playlist)
+		log.Info("end getting playlist")
+	}
+}
+
+func AddPlaylistHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("adding playlist handler")
+		log.Info("start adding playlist")
+
+		name, err := decodePlaylistName(r.Body)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		playlist, err := db.AddPlaylist(r.Context(), name)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/playlists/%d", playlist.ID))
+		writeJSON(log, w, r, http.StatusCreated, playlist)
+		log.Info("end adding playlist")
+	}
+}
+
+func EditPlaylistHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("editing playlist handler")
+		log.Info("start editing playlist")
+
+		playlistID, err := pathID(r, "playlistID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		name, err := decodePlaylistName(r.Body)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		playlist, err := db.UpdatePlaylist(r.Context(), playlistID, name)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		writeJSON(log, w, r, http.StatusOK, playlist)
+		log.Info("end editing playlist")
+	}
+}
+
+func DeletePlaylistHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("deleting playlist handler")
+		log.Info("start deleting playlist")
+
+		playlistID, err := pathID(r, "playlistID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		if err := db.DeletePlaylist(r.Context(), playlistID); err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		log.Info("end deleting playlist", "id", playlistID)
+	}
+}
+
+// AddPlaylistSongHandler adds a song to the playlist, at the end unless a
+// position is given, and returns the resulting playlist.
+func AddPlaylistSongHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("adding playlist song handler")
+		log.Info("start adding song to playlist")
+
+		playlistID, err := pathID(r, "playlistID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		var request models.PlaylistSongRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(log, w, r, errors.InvalidArgument("invalid request body").Wrap(err))
+			return
+		}
+		if request.SongID < 1 {
+			writeError(log, w, r, errors.InvalidArgument("song_id must be a positive number"))
+			return
+		}
+
+		if err := db.AddPlaylistSong(r.Context(), playlistID, request.SongID, request.Position); err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		playlist, err := db.GetPlaylist(r.Context(), playlistID)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		writeJSON(log, w, r, http.StatusCreated, playlist)
+		log.Info("end adding song to playlist", "playlist_id", playlistID, "song_id", request.SongID)
+	}
+}
+
+// MovePlaylistSongHandler moves a song of the playlist to another position
+// and returns the resulting playlist.
+func MovePlaylistSongHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("moving playlist song handler")
+		log.Info("start moving song in playlist")
+
+		playlistID, err := pathID(r, "playlistID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		songID, err := pathID(r, "songID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		var request struct {
+			Position int `json:"position"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(log, w, r, errors.InvalidArgument("invalid request body").Wrap(err))
+			return
+		}
+
+		if err := db.MovePlaylistSong(r.Context(), playlistID, songID, request.Position); err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		playlist, err := db.GetPlaylist(r.Context(), playlistID)
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		writeJSON(log, w, r, http.StatusOK, playlist)
+		log.Info("end moving song in playlist", "playlist_id", playlistID, "song_id", songID)
+	}
+}
+
+func RemovePlaylistSongHandler(log *slog.Logger, db DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		log.Debug("removing playlist song handler")
+		log.Info("start removing song from playlist")
+
+		playlistID, err := pathID(r, "playlistID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		songID, err := pathID(r, "songID")
+		if err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		if err := db.RemovePlaylistSong(r.Context(), playlistID, songID); err != nil {
+			writeError(log, w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		log.Info("end removing song from playlist", "playlist_id", playlistID, "song_id", songID)
+	}
+}
+
+// decodePlaylistName reads the playlist name from the request body.
+func decodePlaylistName(body io.Reader) (string, error) {
+	var request models.PlaylistRequest
+	if err := json.NewDecoder(body).Decode(&request); err != nil {
+		return "", errors.InvalidArgument("invalid request body").Wrap(err)
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return "", errors.InvalidArgument("name must not be empty")
+	}
+
+	return name, nil
+}
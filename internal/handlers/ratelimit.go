@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nongrata2/musiclib/internal/auth"
+	"github.com/nongrata2/musiclib/internal/logging"
+	"github.com/nongrata2/musiclib/internal/ratelimit"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+// RateLimit counts requests against the limiter's budget before passing them
+// to next. The state of the client's bucket is reported in RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers; rejected requests get 429
+// with Retry-After.
+func RateLimit(log *slog.Logger, limiter *ratelimit.Limiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context(), log)
+		client := clientKey(r)
+
+		decision, err := limiter.Allow(r.Context(), client)
+		if err != nil {
+			// the quota store failing should not take the API down with it
+			log.Error("failed to check quota", "client", client, "budget", limiter.Name(), "error", err)
+		}
+
+		if decision.Limit > 0 {
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			w.Header().Set("RateLimit-Reset", seconds(decision.Reset))
+		}
+
+		if !decision.Allowed {
+			w.Header().Set("Retry-After", seconds(decision.RetryAfter))
+
+			details := map[string]any{"budget": limiter.Name()}
+			if decision.QuotaExceeded {
+				writeError(log, w, r, errors.QuotaExceeded("daily quota exceeded").WithDetails(details))
+			} else {
+				writeError(log, w, r, errors.RateLimited("too many requests").WithDetails(details))
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientKey identifies the client a request is counted against: its
+// principal if it has one, its address otherwise, as before authentication.
+func clientKey(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.Method + ":" + principal.Tenant + ":" + principal.Subject
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds formats d as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
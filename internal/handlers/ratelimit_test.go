@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nongrata2/musiclib/internal/ratelimit"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+func TestSeconds(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0"},
+		{time.Nanosecond, "1"},
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+		{59*time.Second + 999*time.Millisecond, "60"},
+		{24 * time.Hour, "86400"},
+	}
+
+	for _, tt := range tests {
+		if got := seconds(tt.d); got != tt.want {
+			t.Errorf("seconds(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+// dayQuota counts requests in memory.
+type dayQuota map[string]int
+
+func (q dayQuota) UseQuota(_ context.Context, client, budget string, day time.Time) (int, error) {
+	key := client + budget + day.String()
+	q[key]++
+	return q[key], nil
+}
+
+func (q dayQuota) PruneQuotas(context.Context, time.Time) error {
+	return nil
+}
+
+func TestRateLimit(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	type response struct {
+		status  int
+		headers map[string]string
+		code    errors.Code
+		// retryAfter bounds Retry-After, which depends on the time of day
+		retryAfter [2]int
+	}
+
+	tests := []struct {
+		name      string
+		cfg       ratelimit.Config
+		responses []response
+	}{
+		{
+			name: "rate",
+			cfg:  ratelimit.Config{Rate: 0.5, Burst: 2},
+			responses: []response{
+				{status: http.StatusNoContent, headers: map[string]string{
+					"RateLimit-Limit": "2", "RateLimit-Remaining": "1", "RateLimit-Reset": "2",
+				}},
+				{status: http.StatusNoContent, headers: map[string]string{
+					"RateLimit-Limit": "2", "RateLimit-Remaining": "0", "RateLimit-Reset": "4",
+				}},
+				{status: http.StatusTooManyRequests, code: errors.CodeRateLimited, headers: map[string]string{
+					"RateLimit-Limit": "2", "RateLimit-Remaining": "0", "RateLimit-Reset": "4", "Retry-After": "2",
+				}},
+			},
+		},
+		{
+			name: "daily quota",
+			cfg:  ratelimit.Config{DailyQuota: 1},
+			responses: []response{
+				{status: http.StatusNoContent, headers: map[string]string{
+					"RateLimit-Limit": "", "Retry-After": "",
+				}},
+				{status: http.StatusTooManyRequests, code: errors.CodeQuotaExceeded, headers: map[string]string{
+					"RateLimit-Limit": "",
+				}, retryAfter: [2]int{1, 24 * 60 * 60}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := ratelimit.New(ratelimit.BudgetRead, tt.cfg, dayQuota{})
+			handler := RateLimit(log, limiter, ok)
+
+			for i, want := range tt.responses {
+				r := httptest.NewRequest(http.MethodGet, "/songs", nil)
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+
+				if w.Code != want.status {
+					t.Fatalf("response %d: status %d, want %d", i, w.Code, want.status)
+				}
+				for name, value := range want.headers {
+					if got := w.Header().Get(name); got != value {
+						t.Errorf("response %d: %s = %q, want %q", i, name, got, value)
+					}
+				}
+				if want.retryAfter != [2]int{} {
+					got, err := strconv.Atoi(w.Header().Get("Retry-After"))
+					if err != nil || got < want.retryAfter[0] || got > want.retryAfter[1] {
+						t.Errorf("response %d: Retry-After = %q, want between %d and %d",
+							i, w.Header().Get("Retry-After"), want.retryAfter[0], want.retryAfter[1])
+					}
+				}
+
+				if want.code == "" {
+					continue
+				}
+				var p problem
+				if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+					t.Fatalf("response %d: invalid problem document: %v", i, err)
+				}
+				if p.Code != want.code || p.Details["budget"] != ratelimit.BudgetRead {
+					t.Errorf("response %d: code %q, details %v, want code %q for budget %s",
+						i, p.Code, p.Details, want.code, ratelimit.BudgetRead)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/nongrata2/musiclib/internal/logging"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details document.
+type problem struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail,omitempty"`
+	Instance string         `json:"instance,omitempty"`
+	Code     errors.Code    `json:"code"`
+	Details  map[string]any `json:"details,omitempty"`
+}
+
+// writeError reports err to the client as a problem document. Errors that
+// are not *errors.AppError are treated as internal and their text is only logged.
+func writeError(log *slog.Logger, w http.ResponseWriter, r *http.Request, err error) {
+	appErr := errors.From(err)
+
+	// middleware outside the routes is passed the global logger
+	log = logging.FromContext(r.Context(), log)
+
+	if appErr.Status >= http.StatusInternalServerError {
+		log.Error("request failed", "status", appErr.Status, "code", appErr.Code, "error", err)
+	} else {
+		log.Warn("request rejected", "status", appErr.Status, "code", appErr.Code, "error", err)
+	}
+
+	p := problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(appErr.Status),
+		Status:   appErr.Status,
+		Detail:   appErr.Message,
+		Instance: r.URL.Path,
+		Code:     appErr.Code,
+		Details:  appErr.Details,
+	}
+
+	jsonData, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		log.Error("failed to encode problem to JSON", "error", err)
+		http.Error(w, http.StatusText(appErr.Status), appErr.Status)
+		return
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(appErr.Status)
+	if _, err := w.Write(jsonData); err != nil {
+		log.Error("error writing", "error", err)
+	}
+}
+
+// WriteError reports err the way handlers do, for use by middleware.
+func WriteError(log *slog.Logger, w http.ResponseWriter, r *http.Request, err error) {
+	writeError(log, w, r, err)
+}
+
+func writeJSON(log *slog.Logger, w http.ResponseWriter, r *http.Request, status int, v any) {
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		writeError(log, w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(jsonData); err != nil {
+		log.Error("error writing", "error", err)
+	}
+}
@@ -0,0 +1,147 @@
+// Package health serves the liveness and readiness probes of the service.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of the service and its components.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusFailing  = "failing"
+)
+
+// Check reports whether a component works.
+type Check func(ctx context.Context) error
+
+type component struct {
+	name     string
+	check    Check
+	critical bool
+}
+
+// Checker runs the checks of the components the service depends on.
+type Checker struct {
+	log     *slog.Logger
+	timeout time.Duration
+
+	components   []component
+	shuttingDown atomic.Bool
+}
+
+// New creates a checker giving each check timeout to finish.
+func New(log *slog.Logger, timeout time.Duration) *Checker {
+	return &Checker{log: log, timeout: timeout}
+}
+
+// Add registers a component the service cannot work without: if its check
+// fails, the service is not ready.
+func (c *Checker) Add(name string, check Check) {
+	c.components = append(c.components, component{name: name, check: check, critical: true})
+}
+
+// AddOptional registers a component the service can do without for a while.
+// A failing check only marks the service degraded.
+func (c *Checker) AddOptional(name string, check Check) {
+	c.components = append(c.components, component{name: name, check: check})
+}
+
+// ShutDown makes the service report not ready, so that no new requests are
+// routed to it while it finishes the current ones.
+func (c *Checker) ShutDown() {
+	c.shuttingDown.Store(true)
+}
+
+type componentReport struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type report struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentReport `json:"components,omitempty"`
+}
+
+// LiveHandler answers 200 as long as the process serves requests.
+func (c *Checker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.write(w, http.StatusOK, report{Status: StatusOK})
+	})
+}
+
+// ReadyHandler runs all checks and answers 200 if the service can take
+// requests, or 503 with the failing components otherwise.
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.shuttingDown.Load() {
+			c.write(w, http.StatusServiceUnavailable, report{Status: StatusFailing})
+			return
+		}
+
+		rep := c.run(r.Context())
+		status := http.StatusOK
+		if rep.Status == StatusFailing {
+			status = http.StatusServiceUnavailable
+			c.log.Warn("service is not ready", "components", rep.Components)
+		}
+		c.write(w, status, rep)
+	})
+}
+
+// run checks all components at once.
+func (c *Checker) run(ctx context.Context) report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	reports := make([]componentReport, len(c.components))
+	var wg sync.WaitGroup
+	for i, comp := range c.components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := comp.check(ctx)
+			reports[i] = componentReport{
+				Status:   StatusOK,
+				Critical: comp.critical,
+				Duration: time.Since(start).Round(time.Millisecond).String(),
+			}
+			if err != nil {
+				reports[i].Status = StatusFailing
+				reports[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	rep := report{Status: StatusOK, Components: make(map[string]componentReport, len(c.components))}
+	for i, comp := range c.components {
+		if reports[i].Status == StatusFailing {
+			if comp.critical {
+				rep.Status = StatusFailing
+			} else if rep.Status == StatusOK {
+				rep.Status = StatusDegraded
+			}
+		}
+		rep.Components[comp.name] = reports[i]
+	}
+	return rep
+}
+
+func (c *Checker) write(w http.ResponseWriter, status int, rep report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(rep); err != nil {
+		c.log.Error("failed to write health report", "error", err)
+	}
+}
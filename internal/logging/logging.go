@@ -0,0 +1,98 @@
+// Package logging builds the application logger and carries a logger for
+// every request in its context, so that everything logged while serving a
+// request can be told apart by its request ID.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Outputs other than a file path.
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+type Config struct {
+	// Level is the least severe level logged: DEBUG, INFO, WARN or ERROR.
+	Level string
+	// Format is text or json.
+	Format string
+	// Output is stdout, stderr or the path of a file records are appended to.
+	Output string
+}
+
+// New returns the logger described by cfg and the file it writes to, if any.
+func New(cfg Config) (*slog.Logger, io.Closer, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, nil, fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+
+	var out io.Writer
+	var closer io.Closer
+	switch cfg.Output {
+	case OutputStdout, "":
+		out = os.Stdout
+	case OutputStderr:
+		out = os.Stderr
+	default:
+		file, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		out, closer = file, file
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case FormatText, "":
+		handler = slog.NewTextHandler(out, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	return slog.New(handler), closer, nil
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying the logger.
+func WithLogger(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// FromContext returns the logger ctx carries, or fallback if it has none.
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if log, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return log
+	}
+	return fallback
+}
+
+// With returns a copy of ctx whose logger adds args to every record. It
+// does nothing if ctx carries no logger.
+func With(ctx context.Context, args ...any) context.Context {
+	log, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	if !ok {
+		return ctx
+	}
+	return WithLogger(ctx, log.With(args...))
+}
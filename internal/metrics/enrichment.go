@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nongrata2/musiclib/internal/models"
+)
+
+// JobCounter counts the enrichment jobs of all tenants by status.
+type JobCounter interface {
+	CountJobs(ctx context.Context) (map[string]int, error)
+}
+
+// queueCollector reports the enrichment queue, queried on every scrape.
+type queueCollector struct {
+	jobs    JobCounter
+	timeout time.Duration
+	desc    *prometheus.Desc
+}
+
+// NewQueueCollector returns a collector of the number of enrichment jobs
+// per status. A query taking longer than timeout fails the gauge.
+func NewQueueCollector(jobs JobCounter, timeout time.Duration) prometheus.Collector {
+	return &queueCollector{
+		jobs:    jobs,
+		timeout: timeout,
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "enrichment", "jobs"),
+			"Enrichment jobs by status.", []string{"status"}, nil),
+	}
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	counts, err := c.jobs.CountJobs(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	for _, status := range []string{models.JobPending, models.JobRunning, models.JobSucceeded, models.JobFailed} {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(counts[status]), status)
+	}
+}
+
+// NewCacheCollectors returns collectors of the song info cache: hits and
+// misses as stats reports them and, if size is not nil, its number of entries.
+func NewCacheCollectors(stats func() (hits, misses int64), size func() int) []prometheus.Collector {
+	cs := []prometheus.Collector{
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "song_info_cache",
+			Name:      "hits_total",
+			Help:      "Song info lookups answered from the cache.",
+		}, func() float64 {
+			hits, _ := stats()
+			return float64(hits)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "song_info_cache",
+			Name:      "misses_total",
+			Help:      "Song info lookups passed on to the external API.",
+		}, func() float64 {
+			_, misses := stats()
+			return float64(misses)
+		}),
+	}
+
+	if size != nil {
+		cs = append(cs, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "song_info_cache",
+			Name:      "entries",
+			Help:      "Entries held by the in-memory cache.",
+		}, func() float64 {
+			return float64(size())
+		}))
+	}
+	return cs
+}
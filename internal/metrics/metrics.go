@@ -0,0 +1,127 @@
+// Package metrics exposes the service's Prometheus metrics: HTTP requests
+// per route, the database pool, external API calls and enrichment.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "musiclib"
+
+// Metrics holds the collectors of the service in a registry of its own.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	externalRequests *prometheus.HistogramVec
+	externalCalls    *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by route pattern, method and status code.",
+		}, []string{"route", "method", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time spent serving HTTP requests by route pattern and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+
+		externalRequests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "external_api",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of single requests to the external API by outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		externalCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "external_api",
+			Name:      "calls_total",
+			Help:      "Song info lookups in the external API by outcome, each possibly spanning several requests.",
+		}, []string{"outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.externalRequests,
+		m.externalCalls,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		Registry: m.registry,
+		// a failing collector, such as the job queue, should not hide the rest
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// UnmatchedRoute labels the requests that match no route.
+const UnmatchedRoute = "unmatched"
+
+// Instrument counts and times requests under the route pattern route finds
+// for them, or UnmatchedRoute. It is meant to wrap the whole server, so that
+// requests rejected before they reach their route, such as unauthenticated
+// ones, are counted as well.
+func (m *Metrics) Instrument(route func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		// patterns are few, each gets its instrumented handler once
+		var handlers sync.Map
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pattern := route(r)
+			if pattern == "" {
+				pattern = UnmatchedRoute
+			}
+
+			h, ok := handlers.Load(pattern)
+			if !ok {
+				h, _ = handlers.LoadOrStore(pattern, m.instrumentRoute(pattern, next))
+			}
+			h.(http.Handler).ServeHTTP(w, r)
+		})
+	}
+}
+
+// instrumentRoute counts and times the requests to next under pattern.
+func (m *Metrics) instrumentRoute(pattern string, next http.Handler) http.Handler {
+	route := prometheus.Labels{"route": pattern}
+	return promhttp.InstrumentHandlerCounter(m.httpRequests.MustCurryWith(route),
+		promhttp.InstrumentHandlerDuration(m.httpDuration.MustCurryWith(route), next))
+}
+
+// ObserveRequest implements externalapi.Observer.
+func (m *Metrics) ObserveRequest(outcome string, duration time.Duration) {
+	m.externalRequests.WithLabelValues(outcome).Observe(duration.Seconds())
+}
+
+// ObserveCall implements externalapi.Observer.
+func (m *Metrics) ObserveCall(outcome string) {
+	m.externalCalls.WithLabelValues(outcome).Inc()
+}
+
+// MustRegister adds collectors of other packages to the registry.
+func (m *Metrics) MustRegister(cs ...prometheus.Collector) {
+	m.registry.MustRegister(cs...)
+}
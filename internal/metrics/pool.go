@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector reports the statistics of a pgx connection pool.
+type poolCollector struct {
+	stat func() *pgxpool.Stat
+
+	acquired    *prometheus.Desc
+	idle        *prometheus.Desc
+	total       *prometheus.Desc
+	max         *prometheus.Desc
+	acquires    *prometheus.Desc
+	emptyWaits  *prometheus.Desc
+	canceled    *prometheus.Desc
+	waitSeconds *prometheus.Desc
+}
+
+// NewPoolCollector returns a collector of the statistics stat returns.
+func NewPoolCollector(stat func() *pgxpool.Stat) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &poolCollector{
+		stat:        stat,
+		acquired:    desc("acquired_connections", "Connections currently in use."),
+		idle:        desc("idle_connections", "Connections currently idle."),
+		total:       desc("connections", "Connections currently open."),
+		max:         desc("max_connections", "Maximum size of the pool."),
+		acquires:    desc("acquires_total", "Connections acquired from the pool."),
+		emptyWaits:  desc("empty_acquires_total", "Acquires that had to wait because no connection was idle."),
+		canceled:    desc("canceled_acquires_total", "Acquires canceled before a connection was available."),
+		waitSeconds: desc("acquire_wait_seconds_total", "Total time spent acquiring connections."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyWaits, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceled, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.waitSeconds, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/nongrata2/musiclib/internal/auth"
+	"github.com/nongrata2/musiclib/internal/logging"
+)
+
+// accessEntry collects what is only known deeper in the chain, once the
+// request was routed and authenticated, for the access log.
+type accessEntry struct {
+	route     string
+	principal *auth.Principal
+}
+
+type accessKey struct{}
+
+// AccessLog logs every request once it was served, with the status, the
+// size of the response body and the time it took. It logs with the request
+// logger, falling back to log.
+func AccessLog(log *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := recorder(w)
+			entry := &accessEntry{}
+
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), accessKey{}, entry)))
+
+			args := []any{"status", rw.statusCode(), "bytes", rw.bytes, "duration", time.Since(start)}
+			if entry.route != "" {
+				args = append(args, "route", entry.route)
+			}
+			if entry.principal != nil {
+				args = append(args, "principal", entry.principal)
+			}
+			logging.FromContext(r.Context(), log).Info("request served", args...)
+		})
+	}
+}
+
+// Route adds the route pattern and the principal, if the request has one,
+// to the request logger and the access log.
+func Route(pattern string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := logging.With(r.Context(), "route", pattern)
+			principal, ok := auth.FromContext(ctx)
+			if ok {
+				ctx = logging.With(ctx, "principal", principal)
+			}
+
+			if entry, ok := ctx.Value(accessKey{}).(*accessEntry); ok {
+				entry.route = pattern
+				entry.principal = principal
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Package middleware holds the HTTP middleware that every request or route
+// passes through before reaching its handler: request IDs, access logging,
+// panic recovery and timeouts.
+package middleware
+
+import "net/http"
+
+// Middleware wraps a handler with extra behaviour.
+type Middleware func(next http.Handler) http.Handler
+
+// Chain wraps h in the middleware, the first one outermost.
+func Chain(h http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// responseRecorder remembers the status and the size of the response
+// written through it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// recorder returns w if it already is a recorder, so that the middleware
+// of a chain share one, or wraps it.
+func recorder(w http.ResponseWriter) *responseRecorder {
+	if rw, ok := w.(*responseRecorder); ok {
+		return rw
+	}
+	return &responseRecorder{ResponseWriter: w}
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the recorder.
+func (rw *responseRecorder) Flush() {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// wroteHeader reports whether the response was already started.
+func (rw *responseRecorder) wroteHeader() bool {
+	return rw.status != 0
+}
+
+// statusCode returns the status sent, which is 200 if the handler sent nothing.
+func (rw *responseRecorder) statusCode() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/nongrata2/musiclib/internal/logging"
+)
+
+// Recover turns a panic in next into an error reported by onError, so that
+// one broken request does not kill the connection without an answer. If the
+// response was already started, the panic can only be logged.
+func Recover(log *slog.Logger, onError func(http.ResponseWriter, *http.Request, error)) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := recorder(w)
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					// the handler gave up on the response on purpose
+					panic(p)
+				}
+
+				logging.FromContext(r.Context(), log).Error("panic while serving request",
+					"panic", p, "stack", string(debug.Stack()))
+				if !rw.wroteHeader() {
+					onError(rw, r, fmt.Errorf("panic: %v", p))
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
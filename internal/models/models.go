@@ -2,24 +2,204 @@ package models
 
 import "time"
 
+// SongRequest adds a song. Details left empty may be filled in from the
+// external API.
 type SongRequest struct {
-	Group    string `json:"group_name"`
-	Songname string `json:"song_name"`
+	Group       string `json:"group_name"`
+	Songname    string `json:"song_name"`
+	ReleaseDate string `json:"release_date,omitempty"`
+	Text        string `json:"text,omitempty"`
+	Link        string `json:"link,omitempty"`
+}
+
+// Lyrics is the text of a song, or the requested page of its verses.
+type Lyrics struct {
+	SongID int    `json:"song_id"`
+	Text   string `json:"text"`
+	Page   int    `json:"page,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
 }
 
+// Enrichment statuses of a song: whether its details were already filled
+// in from the external API. Skipped songs were entered by hand without
+// consulting it.
+const (
+	EnrichmentPending   = "pending"
+	EnrichmentSucceeded = "succeeded"
+	EnrichmentFailed    = "failed"
+	EnrichmentSkipped   = "skipped"
+)
+
+// Song is a song of the library. ReleaseDate is nil while it is unknown.
 type Song struct {
-	ID          int    `db:"id" json:"id"`
-	Group       string `db:"group_name" json:"group_name"`
-	Songname    string `db:"song_name" json:"song_name"`
-	ReleaseDate time.Time `db:"release_date" json:"release_date"`
-	Text        string `db:"text" json:"text"`
-	Link        string `db:"link" json:"link"`
+	ID               int        `db:"id" json:"id"`
+	Group            string     `db:"group_name" json:"group_name"`
+	Songname         string     `db:"song_name" json:"song_name"`
+	ReleaseDate      *time.Time `db:"release_date" json:"release_date"`
+	Text             string     `db:"text" json:"text"`
+	Link             string     `db:"link" json:"link"`
+	EnrichmentStatus string     `db:"enrichment_status" json:"enrichment_status"`
 }
 
+// DeletedSong confirms that a song was deleted.
+type DeletedSong struct {
+	ID      int    `json:"id"`
+	Message string `json:"message"`
+}
+
+// SongPatch is a partial song update. Nil fields are left unchanged, a zero
+// ReleaseDate removes the release date.
+type SongPatch struct {
+	Group       *string
+	Songname    *string
+	ReleaseDate *time.Time
+	Text        *string
+	Link        *string
+}
+
+// SongFilter narrows down the song list. Group, Songname and LinkContains
+// match case-insensitive substrings, the release bounds are inclusive.
 type SongFilter struct {
-	Group       string `json:"group_name"`
-	Songname    string `json:"song_name"`
-	ReleaseDate time.Time `json:"release_date"`
-	Text        string `json:"text"`
-	Link        string `json:"link"`
+	Group          string    `json:"group_name"`
+	Songname       string    `json:"song_name"`
+	ReleaseDate    time.Time `json:"release_date"`
+	ReleasedAfter  time.Time `json:"released_after"`
+	ReleasedBefore time.Time `json:"released_before"`
+	Year           int       `json:"year"`
+	Text           string    `json:"text"`
+	Link           string    `json:"link"`
+	LinkContains   string    `json:"link_contains"`
+	Query          string    `json:"q"`
+}
+
+// SortField is one key of the requested song list order.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// PageRequest selects a page of the song list.
+type PageRequest struct {
+	Sort   []SortField
+	Cursor string
+	Limit  int
+}
+
+// SongPage is one page of the song list. NextCursor is empty on the last
+// page. Total is only counted for the first page.
+type SongPage struct {
+	Items      []Song `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+}
+
+type Group struct {
+	ID        int    `db:"id" json:"id"`
+	Name      string `db:"group_name" json:"group_name"`
+	SongCount int    `db:"song_count" json:"song_count"`
+}
+
+type GroupRequest struct {
+	Name string `json:"group_name"`
+}
+
+// Playlist is a user-curated list of songs. Songs are only loaded for a
+// single playlist, in playlist order.
+type Playlist struct {
+	ID        int            `db:"id" json:"id"`
+	Name      string         `db:"name" json:"name"`
+	SongCount int            `db:"song_count" json:"song_count"`
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+	Songs     []PlaylistItem `json:"songs,omitempty"`
+}
+
+// PlaylistItem is a song at its 1-based position in a playlist.
+type PlaylistItem struct {
+	Position int       `db:"position" json:"position"`
+	AddedAt  time.Time `db:"added_at" json:"added_at"`
+	Song     Song      `json:"song"`
+}
+
+type PlaylistRequest struct {
+	Name string `json:"name"`
+}
+
+// PlaylistSongRequest adds a song to a playlist or moves it there. Position
+// is 1-based; zero appends the song to the end.
+type PlaylistSongRequest struct {
+	SongID   int `json:"song_id"`
+	Position int `json:"position"`
+}
+
+// APIKey describes a key clients authenticate with. The key itself is only
+// shown once, when it is created.
+type APIKey struct {
+	ID         int        `db:"id" json:"id"`
+	Name       string     `db:"name" json:"name"`
+	Prefix     string     `db:"prefix" json:"prefix"`
+	Role       string     `db:"role" json:"role"`
+	TenantID   string     `db:"tenant_id" json:"tenant_id"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+// Job statuses.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job is a background enrichment of a song with data from the external API.
+type Job struct {
+	ID        int       `db:"id" json:"id"`
+	SongID    int       `db:"song_id" json:"song_id"`
+	Status    string    `db:"status" json:"status"`
+	Attempts  int       `db:"attempts" json:"attempts"`
+	LastError string    `db:"last_error" json:"last_error,omitempty"`
+	RunAt     time.Time `db:"run_at" json:"run_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+
+	// TenantID, Group and Songname identify the song for the worker running the job.
+	TenantID string `db:"tenant_id" json:"-"`
+	Group    string `db:"group_name" json:"-"`
+	Songname string `db:"song_name" json:"-"`
+}
+
+// Import row statuses.
+const (
+	ImportCreated   = "created"
+	ImportDuplicate = "duplicate"
+	ImportError     = "error"
+)
+
+// ImportRow is the outcome of importing one line of a song list.
+type ImportRow struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	SongID int    `json:"song_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportedSong is the outcome of adding one song of an imported list to
+// the library.
+type ImportedSong struct {
+	// ID of the added song, 0 if it was not added
+	ID int
+	// Duplicate tells that the song already exists
+	Duplicate bool
+	// Err is why the song could not be added
+	Err error
+}
+
+// ImportReport summarizes a song list import.
+type ImportReport struct {
+	DryRun     bool        `json:"dry_run"`
+	Created    int         `json:"created"`
+	Duplicates int         `json:"duplicates"`
+	Errors     int         `json:"errors"`
+	Rows       []ImportRow `json:"rows"`
 }
@@ -0,0 +1,171 @@
+// Package ratelimit limits how often clients may call the API. Each budget
+// is a token bucket per client, optionally backed by a daily quota kept in
+// the database so that it survives restarts.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Budget names.
+const (
+	BudgetRead   = "read"
+	BudgetEnrich = "enrich"
+	// BudgetAddress is counted per client address before authentication.
+	BudgetAddress = "address"
+)
+
+// QuotaStore counts the requests of a client per budget and day.
+type QuotaStore interface {
+	// UseQuota records a request and returns how many the client made on day.
+	UseQuota(ctx context.Context, client, budget string, day time.Time) (int, error)
+	// PruneQuotas forgets the counts of the days before day.
+	PruneQuotas(ctx context.Context, day time.Time) error
+}
+
+type Config struct {
+	// Rate is the number of requests per second a client may make on
+	// average. Zero disables the bucket, leaving only the daily quota.
+	Rate float64
+	// Burst is the number of requests a client may make at once.
+	Burst int
+	// DailyQuota caps the requests of a client per UTC day. Zero disables it.
+	DailyQuota int
+}
+
+// Decision is the outcome of a request.
+type Decision struct {
+	Allowed bool
+	// QuotaExceeded tells a request over the daily quota from one over the rate.
+	QuotaExceeded bool
+	// Limit and Remaining are the bucket size and the requests left in it.
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until a rejected request may be retried.
+	RetryAfter time.Duration
+}
+
+// idleSweep is how often buckets that have filled up again are dropped.
+const idleSweep = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter enforces one budget.
+type Limiter struct {
+	name   string
+	cfg    Config
+	quotas QuotaStore
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	day       time.Time
+}
+
+// New creates a limiter for the named budget. quotas may be nil if the
+// budget has no daily quota.
+func New(name string, cfg Config, quotas QuotaStore) *Limiter {
+	return &Limiter{
+		name:    name,
+		cfg:     cfg,
+		quotas:  quotas,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (l *Limiter) Name() string {
+	return l.name
+}
+
+// Allow takes a token from the client's bucket and, if there was one,
+// counts the request against the daily quota. An error means the quota
+// could not be checked; the decision is then based on the rate alone.
+func (l *Limiter) Allow(ctx context.Context, client string) (Decision, error) {
+	now := l.now()
+	decision, day, prune := l.take(client, now)
+	if !decision.Allowed || l.cfg.DailyQuota <= 0 || l.quotas == nil {
+		return decision, nil
+	}
+
+	if prune {
+		if err := l.quotas.PruneQuotas(ctx, day); err != nil {
+			return decision, err
+		}
+	}
+
+	used, err := l.quotas.UseQuota(ctx, client, l.name, day)
+	if err != nil {
+		return decision, err
+	}
+	if used > l.cfg.DailyQuota {
+		decision.Allowed = false
+		decision.QuotaExceeded = true
+		decision.RetryAfter = day.AddDate(0, 0, 1).Sub(now)
+	}
+	return decision, nil
+}
+
+// take refills the client's bucket and takes a token from it. It also
+// reports the current day and whether it has just begun.
+func (l *Limiter) take(client string, now time.Time) (Decision, time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= idleSweep {
+		l.sweep(now)
+	}
+
+	day := now.UTC().Truncate(24 * time.Hour)
+	newDay := !day.Equal(l.day)
+	l.day = day
+
+	if l.cfg.Rate <= 0 {
+		return Decision{Allowed: true}, day, newDay
+	}
+
+	burst := float64(l.cfg.Burst)
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate)
+	b.last = now
+
+	decision := Decision{Limit: l.cfg.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = l.duration(1 - b.tokens)
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = l.duration(burst - b.tokens)
+
+	return decision, day, newDay
+}
+
+// sweep drops the buckets that are full, they are the same as new ones.
+func (l *Limiter) sweep(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate >= float64(l.cfg.Burst) {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// duration returns the time it takes to refill the tokens.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.cfg.Rate * float64(time.Second))
+}
@@ -0,0 +1,178 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// clock is a fake time source advanced by the test.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newTestLimiter(cfg Config, quotas QuotaStore) (*Limiter, *clock) {
+	c := &clock{now: testNow}
+	l := New(BudgetRead, cfg, quotas)
+	l.now = c.Now
+	return l, c
+}
+
+func TestLimiterBucket(t *testing.T) {
+	// each step advances the clock by wait, then makes a request
+	type step struct {
+		wait       time.Duration
+		allowed    bool
+		remaining  int
+		reset      time.Duration
+		retryAfter time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		cfg   Config
+		steps []step
+	}{
+		{
+			name: "burst then rejected",
+			cfg:  Config{Rate: 1, Burst: 3},
+			steps: []step{
+				{allowed: true, remaining: 2, reset: time.Second},
+				{allowed: true, remaining: 1, reset: 2 * time.Second},
+				{allowed: true, remaining: 0, reset: 3 * time.Second},
+				{allowed: false, remaining: 0, reset: 3 * time.Second, retryAfter: time.Second},
+			},
+		},
+		{
+			name: "partial refill",
+			cfg:  Config{Rate: 2, Burst: 1},
+			steps: []step{
+				{allowed: true, remaining: 0, reset: 500 * time.Millisecond},
+				{wait: 200 * time.Millisecond, allowed: false, remaining: 0, reset: 300 * time.Millisecond, retryAfter: 300 * time.Millisecond},
+				{wait: 300 * time.Millisecond, allowed: true, remaining: 0, reset: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "refill is capped at burst",
+			cfg:  Config{Rate: 1, Burst: 2},
+			steps: []step{
+				{allowed: true, remaining: 1, reset: time.Second},
+				{allowed: true, remaining: 0, reset: 2 * time.Second},
+				{wait: time.Hour, allowed: true, remaining: 1, reset: time.Second},
+				{allowed: true, remaining: 0, reset: 2 * time.Second},
+				{allowed: false, remaining: 0, reset: 2 * time.Second, retryAfter: time.Second},
+			},
+		},
+		{
+			name: "slow rate",
+			cfg:  Config{Rate: 0.25, Burst: 1},
+			steps: []step{
+				{allowed: true, remaining: 0, reset: 4 * time.Second},
+				{wait: time.Second, allowed: false, remaining: 0, reset: 3 * time.Second, retryAfter: 3 * time.Second},
+				{wait: 3 * time.Second, allowed: true, remaining: 0, reset: 4 * time.Second},
+			},
+		},
+		{
+			name: "rejected requests take nothing",
+			cfg:  Config{Rate: 1, Burst: 1},
+			steps: []step{
+				{allowed: true, remaining: 0, reset: time.Second},
+				{wait: 500 * time.Millisecond, allowed: false, remaining: 0, reset: 500 * time.Millisecond, retryAfter: 500 * time.Millisecond},
+				{allowed: false, remaining: 0, reset: 500 * time.Millisecond, retryAfter: 500 * time.Millisecond},
+				{wait: 500 * time.Millisecond, allowed: true, remaining: 0, reset: time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, c := newTestLimiter(tt.cfg, nil)
+			for i, s := range tt.steps {
+				c.now = c.now.Add(s.wait)
+				d, err := l.Allow(context.Background(), "client")
+				if err != nil {
+					t.Fatalf("step %d: Allow: %v", i, err)
+				}
+
+				want := Decision{
+					Allowed:    s.allowed,
+					Limit:      tt.cfg.Burst,
+					Remaining:  s.remaining,
+					Reset:      s.reset,
+					RetryAfter: s.retryAfter,
+				}
+				if d != want {
+					t.Errorf("step %d: Allow = %+v, want %+v", i, d, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterClientsHaveOwnBuckets(t *testing.T) {
+	l, _ := newTestLimiter(Config{Rate: 1, Burst: 1}, nil)
+
+	for _, client := range []string{"a", "b"} {
+		if d, _ := l.Allow(context.Background(), client); !d.Allowed {
+			t.Errorf("first request of %s rejected", client)
+		}
+	}
+	if d, _ := l.Allow(context.Background(), "a"); d.Allowed {
+		t.Error("second request of a allowed")
+	}
+}
+
+// quotaStore counts requests in memory.
+type quotaStore struct {
+	used   map[time.Time]int
+	pruned []time.Time
+}
+
+func (s *quotaStore) UseQuota(_ context.Context, _, _ string, day time.Time) (int, error) {
+	s.used[day]++
+	return s.used[day], nil
+}
+
+func (s *quotaStore) PruneQuotas(_ context.Context, day time.Time) error {
+	s.pruned = append(s.pruned, day)
+	return nil
+}
+
+func TestLimiterDailyQuota(t *testing.T) {
+	store := &quotaStore{used: make(map[time.Time]int)}
+	l, c := newTestLimiter(Config{DailyQuota: 2}, store)
+
+	tests := []struct {
+		name          string
+		wait          time.Duration
+		allowed       bool
+		quotaExceeded bool
+		retryAfter    time.Duration
+	}{
+		{name: "first", allowed: true},
+		{name: "second", wait: time.Hour, allowed: true},
+		{name: "over quota", wait: time.Hour, quotaExceeded: true, retryAfter: 10 * time.Hour},
+		{name: "still over quota", wait: 9*time.Hour + 30*time.Minute, quotaExceeded: true, retryAfter: 30 * time.Minute},
+		{name: "next day", wait: 30 * time.Minute, allowed: true},
+	}
+
+	for _, tt := range tests {
+		c.now = c.now.Add(tt.wait)
+		d, err := l.Allow(context.Background(), "client")
+		if err != nil {
+			t.Fatalf("%s: Allow: %v", tt.name, err)
+		}
+		if d.Allowed != tt.allowed || d.QuotaExceeded != tt.quotaExceeded || d.RetryAfter != tt.retryAfter {
+			t.Errorf("%s: Allow = %+v, want allowed %v, quota exceeded %v, retry after %v",
+				tt.name, d, tt.allowed, tt.quotaExceeded, tt.retryAfter)
+		}
+	}
+
+	if len(store.pruned) != 2 || !store.pruned[1].Equal(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("pruned %v, want the start and the next day", store.pruned)
+	}
+}
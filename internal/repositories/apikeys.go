@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	stdErrors "errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+// apiKeyUseInterval is how often the use of an API key is recorded at most.
+const apiKeyUseInterval = time.Minute
+
+// apiKeyUseTimeout bounds recording the use of an API key.
+const apiKeyUseTimeout = 5 * time.Second
+
+const apiKeyColumns = "id, name, prefix, role, tenant_id, created_at, last_used_at, revoked_at"
+
+func apiKeyFields(key *models.APIKey) []any {
+	return []any{
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&key.Role,
+		&key.TenantID,
+		&key.CreatedAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+	}
+}
+
+// AddAPIKey stores the hash of a new API key granting the role within the tenant.
+func (db *DB) AddAPIKey(ctx context.Context, name string, hash []byte, prefix, role, tenantID string) (*models.APIKey, error) {
+	log := db.logger(ctx)
+	log.Debug("started adding API key DB")
+
+	query := `
+        INSERT INTO api_keys (name, key_hash, prefix, role, tenant_id)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING ` + apiKeyColumns
+
+	var key models.APIKey
+	if err := db.conn.QueryRow(ctx, query, name, hash, prefix, role, tenantID).Scan(apiKeyFields(&key)...); err != nil {
+		log.Error("failed to add API key", "name", name, "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("ended adding API key DB")
+	return &key, nil
+}
+
+func (db *DB) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	log := db.logger(ctx)
+	log.Debug("started getting API key list DB")
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id`
+
+	rows, err := db.conn.Query(ctx, query)
+	if err != nil {
+		log.Error("failed to fetch API keys", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		if err := rows.Scan(apiKeyFields(&key)...); err != nil {
+			log.Error("failed to scan API key row", "error", err)
+			return nil, dbError(err, nil, nil)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("ended getting API key list DB")
+	return keys, nil
+}
+
+// RevokeAPIKey stops the key from being accepted. Revoking a key twice keeps
+// the time of the first revocation.
+func (db *DB) RevokeAPIKey(ctx context.Context, id int) (*models.APIKey, error) {
+	log := db.logger(ctx)
+	log.Debug("started revoking API key DB")
+
+	query := `
+        UPDATE api_keys
+        SET revoked_at = COALESCE(revoked_at, now())
+        WHERE id = $1
+        RETURNING ` + apiKeyColumns
+
+	var key models.APIKey
+	if err := db.conn.QueryRow(ctx, query, id).Scan(apiKeyFields(&key)...); err != nil {
+		log.Error("failed to revoke API key", "id", id, "error", err)
+		return nil, dbError(err, errors.APIKeyNotFoundErr, nil)
+	}
+
+	log.Debug("ended revoking API key DB")
+	return &key, nil
+}
+
+// LookupAPIKey returns the active key with the given hash, or nil if there
+// is no such key. Its use is recorded in the background, at most once every
+// apiKeyUseInterval, so that authenticating does not wait for a write.
+func (db *DB) LookupAPIKey(ctx context.Context, hash []byte) (*models.APIKey, error) {
+	log := db.logger(ctx)
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`
+
+	var key models.APIKey
+	if err := db.conn.QueryRow(ctx, query, hash).Scan(apiKeyFields(&key)...); err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		log.Error("failed to look up API key", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) >= apiKeyUseInterval {
+		db.recordAPIKeyUse(ctx, key.ID)
+	}
+
+	return &key, nil
+}
+
+// recordAPIKeyUse sets when the key was last used without blocking the
+// caller. Concurrent requests with the same key record its use once.
+func (db *DB) recordAPIKeyUse(ctx context.Context, id int) {
+	now := time.Now()
+	if last, loaded := db.apiKeyUses.LoadOrStore(id, now); loaded {
+		if now.Sub(last.(time.Time)) < apiKeyUseInterval || !db.apiKeyUses.CompareAndSwap(id, last, now) {
+			return
+		}
+	}
+
+	log := db.logger(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), apiKeyUseTimeout)
+		defer cancel()
+
+		query := `UPDATE api_keys SET last_used_at = now() WHERE id = $1`
+		if _, err := db.conn.Exec(ctx, query, id); err != nil {
+			log.Warn("failed to record API key use", "id", id, "error", err)
+		}
+	}()
+}
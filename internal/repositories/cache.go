@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"context"
+	stdErrors "errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nongrata2/musiclib/internal/externalapi"
+)
+
+// SongInfoCache is an externalapi.Cache kept in the song_info_cache table,
+// so cached lookups survive restarts.
+type SongInfoCache struct {
+	db *DB
+}
+
+func NewSongInfoCache(db *DB) *SongInfoCache {
+	return &SongInfoCache{db: db}
+}
+
+func (c *SongInfoCache) Get(ctx context.Context, key string) (externalapi.CacheEntry, bool, error) {
+	log := c.db.logger(ctx)
+	query := `
+        SELECT release_date, text, link, not_found, expires_at
+        FROM song_info_cache
+        WHERE cache_key = $1 AND expires_at > now()
+    `
+
+	var entry externalapi.CacheEntry
+	var releaseDate *time.Time
+	err := c.db.conn.QueryRow(ctx, query, key).Scan(
+		&releaseDate,
+		&entry.Response.Text,
+		&entry.Response.Link,
+		&entry.NotFound,
+		&entry.ExpiresAt,
+	)
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return externalapi.CacheEntry{}, false, nil
+		}
+		log.Error("failed to get cached song info", "error", err)
+		return externalapi.CacheEntry{}, false, dbError(err, nil, nil)
+	}
+
+	if releaseDate != nil {
+		entry.Response.ReleaseDate = *releaseDate
+	}
+	return entry, true, nil
+}
+
+func (c *SongInfoCache) Set(ctx context.Context, key string, entry externalapi.CacheEntry) error {
+	log := c.db.logger(ctx)
+	query := `
+        INSERT INTO song_info_cache (cache_key, release_date, text, link, not_found, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (cache_key) DO UPDATE
+        SET release_date = EXCLUDED.release_date,
+            text = EXCLUDED.text,
+            link = EXCLUDED.link,
+            not_found = EXCLUDED.not_found,
+            expires_at = EXCLUDED.expires_at
+    `
+
+	var releaseDate *time.Time
+	if !entry.NotFound {
+		releaseDate = &entry.Response.ReleaseDate
+	}
+
+	_, err := c.db.conn.Exec(ctx, query,
+		key,
+		releaseDate,
+		entry.Response.Text,
+		entry.Response.Link,
+		entry.NotFound,
+		entry.ExpiresAt,
+	)
+	if err != nil {
+		log.Error("failed to cache song info", "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	return nil
+}
+
+// RunPurge removes expired entries right away and then every interval until
+// ctx is cancelled, so the table does not keep growing between restarts.
+// With a zero interval entries are only removed once.
+func (c *SongInfoCache) RunPurge(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		c.Purge(ctx)
+		return
+	}
+	c.db.log.Info("starting song info cache purge", "interval", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// a failed purge is logged and tried again on the next tick
+		c.Purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			c.db.log.Info("song info cache purge stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes expired entries.
+func (c *SongInfoCache) Purge(ctx context.Context) error {
+	log := c.db.logger(ctx)
+	result, err := c.db.conn.Exec(ctx, `DELETE FROM song_info_cache WHERE expires_at <= now()`)
+	if err != nil {
+		log.Error("failed to purge song info cache", "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	log.Debug("purged song info cache", "removed", result.RowsAffected())
+	return nil
+}
@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	stdErrors "errors"
+	"net/http"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+// dbError translates a database error into an application error. notFound
+// is returned when nothing matched and conflict on a unique violation; when
+// they are nil generic errors are used instead.
+func dbError(err error, notFound, conflict *errors.AppError) error {
+	if stdErrors.Is(err, pgx.ErrNoRows) {
+		if notFound == nil {
+			notFound = errors.NotFound("not found")
+		}
+		return notFound.Wrap(err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !stdErrors.As(err, &pgErr) {
+		return errors.Internal(err)
+	}
+
+	switch pgErr.Code {
+	case pgerrcode.UniqueViolation:
+		if conflict == nil {
+			conflict = errors.Conflict("already exists")
+		}
+		return conflict.Wrap(err)
+	case pgerrcode.ForeignKeyViolation:
+		return errors.New(errors.CodeInvalidReference, http.StatusUnprocessableEntity, "referenced resource does not exist").
+			WithDetails(map[string]any{"constraint": pgErr.ConstraintName}).
+			Wrap(err)
+	case pgerrcode.CheckViolation, pgerrcode.NotNullViolation, pgerrcode.InvalidTextRepresentation,
+		pgerrcode.DatetimeFieldOverflow, pgerrcode.NumericValueOutOfRange:
+		return errors.InvalidArgument("invalid value").Wrap(err)
+	}
+
+	return errors.Internal(err)
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nongrata2/musiclib/internal/models"
+)
+
+// conditions accumulates WHERE conditions together with their positional arguments.
+type conditions struct {
+	list []string
+	args []any
+}
+
+// bind appends an argument and returns its placeholder number.
+func (c *conditions) bind(value any) int {
+	c.args = append(c.args, value)
+	return len(c.args)
+}
+
+// add appends a condition built from format, where every %[1]d verb is
+// replaced with the placeholder number of value. It returns that number.
+func (c *conditions) add(format string, value any) int {
+	n := c.bind(value)
+	c.list = append(c.list, fmt.Sprintf(format, n))
+	return n
+}
+
+func (c *conditions) where() string {
+	if len(c.list) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(c.list, " AND ")
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern turns user input into an ILIKE pattern matching it as a substring.
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// tsQueryFormat builds the tsquery for a full-text search argument. The
+// lyrics are indexed with both stemmers, so a line matches whichever
+// language it was written in.
+const tsQueryFormat = "(websearch_to_tsquery('russian', $%[1]d) || websearch_to_tsquery('english', $%[1]d))"
+
+func tsQuery(n int) string {
+	return fmt.Sprintf(tsQueryFormat, n)
+}
+
+// songConditions translates the filter into conditions over songs s joined with groups g.
+// It also returns the placeholder of the full-text query, or 0 if there is none.
+func songConditions(filters models.SongFilter) (*conditions, int) {
+	c := &conditions{}
+
+	if filters.Group != "" {
+		c.add("g.group_name ILIKE $%[1]d", containsPattern(filters.Group))
+	}
+	if filters.Songname != "" {
+		c.add("s.song_name ILIKE $%[1]d", containsPattern(filters.Songname))
+	}
+	if !filters.ReleaseDate.IsZero() {
+		c.add("s.release_date = $%[1]d", filters.ReleaseDate)
+	}
+	if !filters.ReleasedAfter.IsZero() {
+		c.add("s.release_date >= $%[1]d", filters.ReleasedAfter)
+	}
+	if !filters.ReleasedBefore.IsZero() {
+		c.add("s.release_date <= $%[1]d", filters.ReleasedBefore)
+	}
+	if filters.Year != 0 {
+		c.add("s.release_date >= make_date($%[1]d::int, 1, 1) AND s.release_date < make_date($%[1]d::int + 1, 1, 1)", filters.Year)
+	}
+	if filters.Text != "" {
+		c.add("s.text = $%[1]d", filters.Text)
+	}
+	if filters.Link != "" {
+		c.add("s.link = $%[1]d", filters.Link)
+	}
+	if filters.LinkContains != "" {
+		c.add("s.link ILIKE $%[1]d", containsPattern(filters.LinkContains))
+	}
+
+	var queryArg int
+	if filters.Query != "" {
+		queryArg = c.add("s.text_search @@ "+tsQueryFormat, filters.Query)
+	}
+
+	return c, queryArg
+}
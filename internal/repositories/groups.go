@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+func (db *DB) GetGroups(ctx context.Context) ([]models.Group, error) {
+	log := db.logger(ctx)
+	log.Debug("started getting group list DB")
+	groups := []models.Group{}
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT g.id, g.group_name, COUNT(s.id)
+        FROM groups g
+        LEFT JOIN songs s ON s.group_id = g.id
+        WHERE g.tenant_id = $1
+        GROUP BY g.id, g.group_name
+        ORDER BY g.group_name
+    `
+
+	rows, err := db.conn.Query(ctx, query, tid)
+	if err != nil {
+		log.Error("failed to fetch groups", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var group models.Group
+		if err := rows.Scan(&group.ID, &group.Name, &group.SongCount); err != nil {
+			log.Error("failed to scan group row", "error", err)
+			return nil, dbError(err, nil, nil)
+		}
+		groups = append(groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("ended getting group list DB")
+	return groups, nil
+}
+
+func (db *DB) GetGroup(ctx context.Context, id int) (*models.Group, error) {
+	log := db.logger(ctx)
+	log.Debug("started getting group DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT g.id, g.group_name, COUNT(s.id)
+        FROM groups g
+        LEFT JOIN songs s ON s.group_id = g.id
+        WHERE g.tenant_id = $1 AND g.id = $2
+        GROUP BY g.id, g.group_name
+    `
+
+	var group models.Group
+	err = db.conn.QueryRow(ctx, query, tid, id).Scan(&group.ID, &group.Name, &group.SongCount)
+	if err != nil {
+		log.Error("failed to get group", "id", id, "error", err)
+		return nil, dbError(err, errors.GroupNotFoundErr, nil)
+	}
+
+	log.Debug("ended getting group DB")
+	return &group, nil
+}
+
+func (db *DB) AddGroup(ctx context.Context, name string) (*models.Group, error) {
+	log := db.logger(ctx)
+	log.Debug("started adding group DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        INSERT INTO groups (tenant_id, group_name)
+        VALUES ($1, $2)
+        RETURNING id, group_name
+    `
+
+	var group models.Group
+	err = db.conn.QueryRow(ctx, query, tid, name).Scan(&group.ID, &group.Name)
+	if err != nil {
+		log.Error("failed to add group", "group_name", name, "error", err)
+		return nil, dbError(err, nil, errors.GroupExistsErr)
+	}
+
+	log.Debug("ended adding group DB")
+	return &group, nil
+}
+
+func (db *DB) UpdateGroup(ctx context.Context, id int, name string) (*models.Group, error) {
+	log := db.logger(ctx)
+	log.Debug("started updating group DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        UPDATE groups
+        SET group_name = $1
+        WHERE tenant_id = $2 AND id = $3
+        RETURNING id, group_name, (SELECT COUNT(*) FROM songs WHERE tenant_id = $2 AND group_id = $3)
+    `
+
+	var group models.Group
+	err = db.conn.QueryRow(ctx, query, name, tid, id).Scan(&group.ID, &group.Name, &group.SongCount)
+	if err != nil {
+		log.Error("failed to update group", "id", id, "group_name", name, "error", err)
+		return nil, dbError(err, errors.GroupNotFoundErr, errors.GroupExistsErr)
+	}
+
+	log.Debug("ended updating group DB")
+	return &group, nil
+}
+
+// DeleteGroup removes the group. A group that still has songs is only
+// removed with cascade, together with all of its songs.
+func (db *DB) DeleteGroup(ctx context.Context, id int, cascade bool) error {
+	log := db.logger(ctx)
+	log.Debug("started deleting group DB", "cascade", cascade)
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return dbError(err, nil, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	// the lock keeps songs from being added to the group until it is gone
+	var songs int
+	query := `
+        SELECT (SELECT count(*) FROM songs s WHERE s.tenant_id = g.tenant_id AND s.group_id = g.id)
+        FROM groups g
+        WHERE g.tenant_id = $1 AND g.id = $2
+        FOR UPDATE
+    `
+	if err := tx.QueryRow(ctx, query, tid, id).Scan(&songs); err != nil {
+		log.Warn("failed to find group", "id", id, "error", err)
+		return dbError(err, errors.GroupNotFoundErr, nil)
+	}
+	if songs > 0 && !cascade {
+		log.Warn("group still has songs", "id", id, "songs", songs)
+		return errors.GroupHasSongsErr.WithDetails(map[string]any{"songs": songs})
+	}
+
+	query = `DELETE FROM groups WHERE tenant_id = $1 AND id = $2`
+	if _, err := tx.Exec(ctx, query, tid, id); err != nil {
+		log.Error("failed to delete group", "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	log.Debug("ended deleting group DB", "songs", songs)
+	return nil
+}
@@ -0,0 +1,215 @@
+package repositories
+
+import (
+	"context"
+	stdErrors "errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/nongrata2/musiclib/internal/models"
+)
+
+// importBatchSize is the number of songs sent to the database in one round trip.
+const importBatchSize = 500
+
+const importSongQuery = `
+    WITH added AS (
+        INSERT INTO songs (tenant_id, group_id, song_name, release_date, text, link, enrichment_status)
+        SELECT tenant_id, id, $3, $4, $5, $6, $7
+        FROM groups
+        WHERE tenant_id = $1 AND group_name = $2
+        ON CONFLICT DO NOTHING
+        RETURNING tenant_id, id, enrichment_status
+    ), jobs AS (
+        INSERT INTO enrichment_jobs (tenant_id, song_id)
+        SELECT tenant_id, id FROM added WHERE enrichment_status = 'pending'
+    )
+    SELECT id FROM added
+`
+
+// importDuplicatesQuery finds the songs of a list that already exist,
+// either in the library or earlier in the list, by their number in the list.
+const importDuplicatesQuery = `
+    SELECT l.n
+    FROM (
+        SELECT n, group_name, song_name,
+               row_number() OVER (PARTITION BY group_name, lower(song_name) ORDER BY n) AS seen
+        FROM unnest($2::text[], $3::text[]) WITH ORDINALITY AS l(group_name, song_name, n)
+    ) l
+    WHERE l.seen > 1 OR EXISTS (
+        SELECT 1
+        FROM songs s
+        JOIN groups g ON g.tenant_id = s.tenant_id AND g.id = s.group_id
+        WHERE s.tenant_id = $1 AND g.group_name = l.group_name AND lower(s.song_name) = lower(l.song_name)
+    )
+`
+
+// ImportSongs adds the songs in a single transaction and returns the outcome
+// for each of them: the id of the added song, a duplicate for a song that
+// already exists, either in the library or earlier in the list, or the
+// error the database rejected it with. A rejected song does not stop the
+// others from being added. Pending songs get an enrichment job. With dryRun
+// nothing is written, the songs are only looked up to find the duplicates.
+func (db *DB) ImportSongs(ctx context.Context, songs []models.Song, dryRun bool) ([]models.ImportedSong, error) {
+	log := db.logger(ctx)
+	log.Debug("started importing songs DB", "count", len(songs), "dry_run", dryRun)
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return db.importDuplicates(ctx, tid, songs)
+	}
+
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	results := make([]models.ImportedSong, len(songs))
+	for start := 0; start < len(songs); start += importBatchSize {
+		end := min(start+importBatchSize, len(songs))
+
+		// batches are sent whole first, a rejected one is retried song by
+		// song to tell which of its songs were rejected
+		err := importSavepoint(ctx, tx, func() error {
+			return importBatch(ctx, tx, tid, songs[start:end], results[start:end])
+		})
+		if err == nil {
+			continue
+		}
+		if !rejected(err) {
+			log.Error("failed to import songs", "error", err)
+			return nil, dbError(err, nil, nil)
+		}
+
+		for i := start; i < end; i++ {
+			err := importSavepoint(ctx, tx, func() error {
+				return importBatch(ctx, tx, tid, songs[i:i+1], results[i:i+1])
+			})
+			if err == nil {
+				continue
+			}
+			if !rejected(err) {
+				log.Error("failed to import songs", "error", err)
+				return nil, dbError(err, nil, nil)
+			}
+
+			log.Warn("song rejected by the database", "group", songs[i].Group, "song", songs[i].Songname, "error", err)
+			results[i] = models.ImportedSong{Err: dbError(err, nil, nil)}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("ended importing songs DB")
+	return results, nil
+}
+
+// importBatch adds the songs and their groups in one round trip, storing
+// the id of every added song in results.
+func importBatch(ctx context.Context, tx pgx.Tx, tid string, songs []models.Song, results []models.ImportedSong) error {
+	groupNames := make([]string, 0, len(songs))
+	for _, song := range songs {
+		groupNames = append(groupNames, song.Group)
+	}
+
+	batch := &pgx.Batch{}
+	batch.Queue(`
+        INSERT INTO groups (tenant_id, group_name)
+        SELECT DISTINCT $1, unnest($2::text[])
+        ON CONFLICT (tenant_id, group_name) DO NOTHING
+    `, tid, groupNames)
+
+	for i, song := range songs {
+		batch.Queue(importSongQuery,
+			tid,
+			song.Group,
+			song.Songname,
+			song.ReleaseDate,
+			song.Text,
+			song.Link,
+			song.EnrichmentStatus,
+		).QueryRow(func(row pgx.Row) error {
+			results[i] = models.ImportedSong{}
+			err := row.Scan(&results[i].ID)
+			if stdErrors.Is(err, pgx.ErrNoRows) {
+				// the song already exists
+				results[i].Duplicate = true
+				return nil
+			}
+			return err
+		})
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// importDuplicates reports which of the songs would not be added because
+// they already exist, without writing anything.
+func (db *DB) importDuplicates(ctx context.Context, tid string, songs []models.Song) ([]models.ImportedSong, error) {
+	log := db.logger(ctx)
+
+	groupNames := make([]string, len(songs))
+	songNames := make([]string, len(songs))
+	for i, song := range songs {
+		groupNames[i] = song.Group
+		songNames[i] = song.Songname
+	}
+
+	rows, err := db.conn.Query(ctx, importDuplicatesQuery, tid, groupNames, songNames)
+	if err != nil {
+		log.Error("failed to look up imported songs", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+	duplicates, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		log.Error("failed to scan imported songs", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	results := make([]models.ImportedSong, len(songs))
+	for _, n := range duplicates {
+		// ordinality counts from 1
+		results[n-1].Duplicate = true
+	}
+
+	log.Debug("ended checking imported songs DB", "duplicates", len(duplicates))
+	return results, nil
+}
+
+// importSavepoint runs add within a savepoint, so that when the database
+// rejects what add writes only that is undone and the import goes on.
+func importSavepoint(ctx context.Context, tx pgx.Tx, add func() error) error {
+	if _, err := tx.Exec(ctx, "SAVEPOINT import"); err != nil {
+		return err
+	}
+
+	if err := add(); err != nil {
+		if !rejected(err) {
+			return err
+		}
+		if _, rbErr := tx.Exec(ctx, "ROLLBACK TO SAVEPOINT import"); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+
+	_, err := tx.Exec(ctx, "RELEASE SAVEPOINT import")
+	return err
+}
+
+// rejected tells whether the database refused a statement, as opposed to
+// the connection or the context failing, after which the import cannot go on.
+func rejected(err error) bool {
+	var pgErr *pgconn.PgError
+	return stdErrors.As(err, &pgErr)
+}
@@ -0,0 +1,296 @@
+package repositories
+
+import (
+	"context"
+	stdErrors "errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+// jobColumns selects a job from enrichment_jobs j in the order jobFields expects.
+const jobColumns = "j.id, j.song_id, j.status, j.attempts, COALESCE(j.last_error, ''), j.run_at, j.created_at, j.updated_at"
+
+func jobFields(job *models.Job) []any {
+	return []any{
+		&job.ID,
+		&job.SongID,
+		&job.Status,
+		&job.Attempts,
+		&job.LastError,
+		&job.RunAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	}
+}
+
+// AddPending saves a song whose details are not known yet together with a
+// job that fills them in later.
+func (db *DB) AddPending(ctx context.Context, song models.Song) (*models.Song, *models.Job, error) {
+	log := db.logger(ctx)
+	log.Debug("started adding pending song DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, nil, dbError(err, nil, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	groupID, err := db.groupID(ctx, tx, song.Group)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := `
+        INSERT INTO songs (tenant_id, group_id, song_name, release_date, text, link, enrichment_status)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING ` + songReturning
+
+	var addedSong models.Song
+	err = tx.QueryRow(ctx, query,
+		tid,
+		groupID,
+		song.Songname,
+		song.ReleaseDate,
+		song.Text,
+		song.Link,
+		models.EnrichmentPending,
+	).Scan(songFields(&addedSong)...)
+	if err != nil {
+		log.Error("failed to add pending song", "error", err)
+		return nil, nil, db.songConflict(ctx, dbError(err, nil, errors.SongExistsErr), song.Group, song.Songname)
+	}
+
+	query = `
+        INSERT INTO enrichment_jobs AS j (tenant_id, song_id)
+        VALUES ($1, $2)
+        RETURNING ` + jobColumns
+
+	var job models.Job
+	if err := tx.QueryRow(ctx, query, tid, addedSong.ID).Scan(jobFields(&job)...); err != nil {
+		log.Error("failed to add enrichment job", "song_id", addedSong.ID, "error", err)
+		return nil, nil, dbError(err, nil, nil)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("ended adding pending song DB", "song_id", addedSong.ID, "job_id", job.ID)
+	return &addedSong, &job, nil
+}
+
+func (db *DB) GetJob(ctx context.Context, id int) (*models.Job, error) {
+	log := db.logger(ctx)
+	log.Debug("started getting job DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT ` + jobColumns + `
+        FROM enrichment_jobs j
+        WHERE j.tenant_id = $1 AND j.id = $2
+    `
+
+	var job models.Job
+	if err := db.conn.QueryRow(ctx, query, tid, id).Scan(jobFields(&job)...); err != nil {
+		log.Error("failed to get job", "id", id, "error", err)
+		return nil, dbError(err, errors.JobNotFoundErr, nil)
+	}
+
+	log.Debug("ended getting job DB")
+	return &job, nil
+}
+
+// ClaimJob takes the next due job of any tenant and marks it running for
+// lease. A job whose worker died is due again once the lease is over. It
+// returns nil when there is nothing to do.
+func (db *DB) ClaimJob(ctx context.Context, lease time.Duration) (*models.Job, error) {
+	log := db.logger(ctx)
+	query := `
+        UPDATE enrichment_jobs j
+        SET status = 'running',
+            attempts = j.attempts + 1,
+            run_at = now() + $1::interval,
+            updated_at = now()
+        FROM (
+            SELECT id
+            FROM enrichment_jobs
+            WHERE status IN ('pending', 'running') AND run_at <= now()
+            ORDER BY run_at
+            LIMIT 1
+            FOR UPDATE SKIP LOCKED
+        ) next, songs s, groups g
+        WHERE j.id = next.id AND s.id = j.song_id AND g.id = s.group_id
+        RETURNING ` + jobColumns + `, j.tenant_id, g.group_name, s.song_name`
+
+	var job models.Job
+	err := db.conn.QueryRow(allTenants(ctx), query, lease).Scan(append(jobFields(&job), &job.TenantID, &job.Group, &job.Songname)...)
+	if err != nil {
+		if stdErrors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		log.Error("failed to claim job", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("claimed job DB", "id", job.ID, "song_id", job.SongID, "attempt", job.Attempts)
+	return &job, nil
+}
+
+// CompleteJob fills in the details of the job's song. Fields that were set in
+// the meantime are kept.
+func (db *DB) CompleteJob(ctx context.Context, id int, details models.Song) error {
+	log := db.logger(ctx)
+	log.Debug("started completing job DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return dbError(err, nil, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	var songID int
+	query := `
+        UPDATE enrichment_jobs
+        SET status = 'succeeded', last_error = NULL, updated_at = now()
+        WHERE tenant_id = $1 AND id = $2
+        RETURNING song_id
+    `
+	if err := tx.QueryRow(ctx, query, tid, id).Scan(&songID); err != nil {
+		log.Error("failed to complete job", "id", id, "error", err)
+		return dbError(err, errors.JobNotFoundErr, nil)
+	}
+
+	query = `
+        UPDATE songs
+        SET release_date = COALESCE(release_date, $1),
+            text = CASE WHEN text = '' THEN $2 ELSE text END,
+            link = CASE WHEN link = '' THEN $3 ELSE link END,
+            enrichment_status = 'succeeded'
+        WHERE tenant_id = $4 AND id = $5
+    `
+	_, err = tx.Exec(ctx, query,
+		details.ReleaseDate,
+		details.Text,
+		details.Link,
+		tid,
+		songID,
+	)
+	if err != nil {
+		log.Error("failed to enrich song", "song_id", songID, "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	log.Debug("ended completing job DB")
+	return nil
+}
+
+// RetryJob puts the job back into the queue to run again at runAt.
+func (db *DB) RetryJob(ctx context.Context, id int, lastErr string, runAt time.Time) error {
+	log := db.logger(ctx)
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE enrichment_jobs
+        SET status = 'pending', last_error = $1, run_at = $2, updated_at = now()
+        WHERE tenant_id = $3 AND id = $4
+    `
+
+	result, err := db.conn.Exec(ctx, query, lastErr, runAt, tid, id)
+	if err != nil {
+		log.Error("failed to reschedule job", "id", id, "error", err)
+		return dbError(err, nil, nil)
+	}
+	if result.RowsAffected() == 0 {
+		return errors.JobNotFoundErr
+	}
+
+	return nil
+}
+
+// FailJob gives up on the job and marks its song as failed to enrich.
+func (db *DB) FailJob(ctx context.Context, id int, lastErr string) error {
+	log := db.logger(ctx)
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        WITH failed AS (
+            UPDATE enrichment_jobs
+            SET status = 'failed', last_error = $1, updated_at = now()
+            WHERE tenant_id = $2 AND id = $3
+            RETURNING song_id
+        )
+        UPDATE songs
+        SET enrichment_status = 'failed'
+        WHERE tenant_id = $2 AND id = (SELECT song_id FROM failed)
+    `
+
+	if _, err := db.conn.Exec(ctx, query, lastErr, tid, id); err != nil {
+		log.Error("failed to mark job failed", "id", id, "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	return nil
+}
+
+// CountJobs returns the number of jobs of all tenants per status.
+func (db *DB) CountJobs(ctx context.Context) (map[string]int, error) {
+	log := db.logger(ctx)
+	query := `SELECT status, count(*) FROM enrichment_jobs GROUP BY status`
+
+	rows, err := db.conn.Query(allTenants(ctx), query)
+	if err != nil {
+		log.Error("failed to count jobs", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			log.Error("failed to scan job count", "error", err)
+			return nil, dbError(err, nil, nil)
+		}
+		counts[status] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	return counts, nil
+}
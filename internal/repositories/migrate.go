@@ -1,9 +1,17 @@
 package repositories
 
 import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/pgx"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
+	stdPgx "github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/stdlib"
 
 	"github.com/nongrata2/musiclib/migrations"
@@ -45,3 +53,54 @@ func (db *DB) Migrate() error {
 	db.log.Debug("migration finished")
 	return nil
 }
+
+// Ping checks that the database can be reached.
+func (db *DB) Ping(ctx context.Context) error {
+	return db.conn.Ping(ctx)
+}
+
+// CheckMigrations reports an error unless the database schema is at the
+// version of the newest embedded migration.
+func (db *DB) CheckMigrations(ctx context.Context) error {
+	expected, err := latestMigration()
+	if err != nil {
+		return err
+	}
+
+	var version uint
+	var dirty bool
+	err = db.conn.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations`).Scan(&version, &dirty)
+	if stdErrors.Is(err, stdPgx.ErrNoRows) {
+		return fmt.Errorf("no migrations applied, expected version %d", expected)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read migration version: %w", err)
+	}
+
+	switch {
+	case dirty:
+		return fmt.Errorf("migration %d failed halfway and needs fixing", version)
+	case version != expected:
+		return fmt.Errorf("database is at version %d, expected %d", version, expected)
+	}
+	return nil
+}
+
+// latestMigration returns the version of the newest embedded migration.
+func latestMigration() (uint, error) {
+	names, err := fs.Glob(migrations.MigrationFiles, "*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest uint
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration file name %q", name)
+		}
+		latest = max(latest, uint(version))
+	}
+	return latest, nil
+}
@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+// sortKey is one column of the song list order. The song id is always one
+// of the keys, so the order is total and can be resumed from a cursor.
+type sortKey struct {
+	name    string // name recorded in cursors
+	expr    string // SQL expression over songs s joined with groups g
+	sqlType string // type the cursor value is cast back to
+	desc    bool
+}
+
+var idSortKey = sortKey{name: "id", expr: "s.id", sqlType: "bigint"}
+
+// songSortKeys is the whitelist of fields the song list can be sorted by.
+// Only expressions from here ever get into ORDER BY. Songs without a known
+// release date sort after all others. The songs expressions are indexed
+// together with the id, see migration 000014; keep them in sync.
+var songSortKeys = map[string]sortKey{
+	"id":           idSortKey,
+	"release_date": {name: "release_date", expr: "COALESCE(s.release_date, 'infinity'::date)", sqlType: "date"},
+	"song_name":    {name: "song_name", expr: "s.song_name", sqlType: "text"},
+	"group_name":   {name: "group_name", expr: "g.group_name", sqlType: "text"},
+}
+
+// songOrder resolves the requested sort fields. Without any, full-text
+// results are ordered by relevance and everything else by id.
+func songOrder(fields []models.SortField, queryArg int) ([]sortKey, error) {
+	if len(fields) == 0 {
+		if queryArg != 0 {
+			return []sortKey{rankSortKey(queryArg), idSortKey}, nil
+		}
+		return []sortKey{idSortKey}, nil
+	}
+
+	keys := make([]sortKey, 0, len(fields)+1)
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		key, ok := songSortKeys[field.Field]
+		if !ok || seen[field.Field] {
+			return nil, errors.InvalidSortErr
+		}
+		seen[field.Field] = true
+		key.desc = field.Desc
+		keys = append(keys, key)
+	}
+
+	if !seen[idSortKey.name] {
+		keys = append(keys, idSortKey)
+	}
+	return keys, nil
+}
+
+func rankSortKey(queryArg int) sortKey {
+	return sortKey{
+		name:    "rank",
+		expr:    fmt.Sprintf("ts_rank(s.text_search, %s)", tsQuery(queryArg)),
+		sqlType: "real",
+		desc:    true,
+	}
+}
+
+// signature identifies the order a cursor was issued for.
+func signature(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.name
+		if key.desc {
+			parts[i] = "-" + key.name
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func orderBy(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.expr
+		if key.desc {
+			parts[i] += " DESC"
+		}
+	}
+	return " ORDER BY " + strings.Join(parts, ", ")
+}
+
+// keyColumns selects the text form of every sort key, used to build the next cursor.
+func keyColumns(keys []sortKey) string {
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, ", (%s)::text", key.expr)
+	}
+	return b.String()
+}
+
+// cursor is the position right after the last row of a page. Cursors are
+// not signed, clients can forge them: their values are only ever bound as
+// parameters of the sort key types, so a forged cursor can do no more than
+// start the page somewhere else.
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+func encodeCursor(keys []sortKey, values []string) string {
+	data, _ := json.Marshal(cursor{Sort: signature(keys), Values: values})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, keys []sortKey) ([]string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.InvalidCursorErr
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.InvalidCursorErr
+	}
+	if c.Sort != signature(keys) || len(c.Values) != len(keys) {
+		return nil, errors.InvalidCursorErr
+	}
+
+	return c.Values, nil
+}
+
+// after adds the condition selecting rows that come after the cursor values
+// in the order given by keys. When all keys are sorted the same way it is a
+// row comparison, which an index on the keys can serve:
+//
+//	(k1, k2, ...) > (v1, v2, ...)
+//
+// Otherwise it is expanded into
+//
+//	k1 > v1 OR (k1 = v1 AND k2 > v2) OR ...
+func (c *conditions) after(keys []sortKey, values []string) {
+	placeholders := make([]string, len(keys))
+	for i, key := range keys {
+		placeholders[i] = fmt.Sprintf("$%d::%s", c.bind(values[i]), key.sqlType)
+	}
+
+	if sameDirection(keys) {
+		exprs := make([]string, len(keys))
+		for i, key := range keys {
+			exprs[i] = key.expr
+		}
+		op := ">"
+		if keys[0].desc {
+			op = "<"
+		}
+		c.list = append(c.list, fmt.Sprintf("((%s) %s (%s))",
+			strings.Join(exprs, ", "), op, strings.Join(placeholders, ", ")))
+		return
+	}
+
+	alternatives := make([]string, len(keys))
+	for i, key := range keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("%s = %s", keys[j].expr, placeholders[j]))
+		}
+		op := ">"
+		if key.desc {
+			op = "<"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s %s", key.expr, op, placeholders[i]))
+		alternatives[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+
+	c.list = append(c.list, "("+strings.Join(alternatives, " OR ")+")")
+}
+
+func sameDirection(keys []sortKey) bool {
+	for _, key := range keys[1:] {
+		if key.desc != keys[0].desc {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"encoding/base64"
+	stdErrors "errors"
+	"slices"
+	"testing"
+
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+func mustSongOrder(t *testing.T, fields ...models.SortField) []sortKey {
+	t.Helper()
+	keys, err := songOrder(fields, 0)
+	if err != nil {
+		t.Fatalf("songOrder(%v): %v", fields, err)
+	}
+	return keys
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []sortKey
+		values []string
+	}{
+		{
+			name:   "by id",
+			keys:   mustSongOrder(t),
+			values: []string{"42"},
+		},
+		{
+			name:   "by several fields",
+			keys:   mustSongOrder(t, models.SortField{Field: "group_name"}, models.SortField{Field: "release_date", Desc: true}),
+			values: []string{"Muse", "2006-07-16", "7"},
+		},
+		{
+			name:   "by relevance",
+			keys:   []sortKey{rankSortKey(1), idSortKey},
+			values: []string{"0.0607927", "3"},
+		},
+		{
+			name:   "values needing escaping",
+			keys:   mustSongOrder(t, models.SortField{Field: "song_name"}),
+			values: []string{`"Quoted", with\ slashes / and ünicode`, "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(encodeCursor(tt.keys, tt.values), tt.keys)
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if !slices.Equal(got, tt.values) {
+				t.Errorf("decodeCursor = %q, want %q", got, tt.values)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	byName := mustSongOrder(t, models.SortField{Field: "song_name"})
+	byNameDesc := mustSongOrder(t, models.SortField{Field: "song_name", Desc: true})
+	byID := mustSongOrder(t)
+
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+		keys   []sortKey
+	}{
+		{
+			name:   "not base64",
+			cursor: "not a cursor!",
+			keys:   byID,
+		},
+		{
+			name:   "padded base64",
+			cursor: base64.URLEncoding.EncodeToString([]byte(`{"s":"id","v":["1"]}`)),
+			keys:   byID,
+		},
+		{
+			name:   "not JSON",
+			cursor: encode("id=1"),
+			keys:   byID,
+		},
+		{
+			name:   "wrong value type",
+			cursor: encode(`{"s":"id","v":[1]}`),
+			keys:   byID,
+		},
+		{
+			name:   "issued for another order",
+			cursor: encodeCursor(byName, []string{"Hysteria", "1"}),
+			keys:   byID,
+		},
+		{
+			name:   "issued for the reverse order",
+			cursor: encodeCursor(byName, []string{"Hysteria", "1"}),
+			keys:   byNameDesc,
+		},
+		{
+			name:   "sort rewritten",
+			cursor: encode(`{"s":"-id","v":["1"]}`),
+			keys:   byID,
+		},
+		{
+			name:   "value added",
+			cursor: encode(`{"s":"id","v":["1","2"]}`),
+			keys:   byID,
+		},
+		{
+			name:   "value removed",
+			cursor: encode(`{"s":"song_name,id","v":["Hysteria"]}`),
+			keys:   byName,
+		},
+		{
+			name:   "empty",
+			cursor: "",
+			keys:   byID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := decodeCursor(tt.cursor, tt.keys)
+			if !stdErrors.Is(err, errors.InvalidCursorErr) {
+				t.Errorf("decodeCursor = %q, %v, want %v", values, err, errors.InvalidCursorErr)
+			}
+		})
+	}
+}
+
+func TestConditionsAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []sortKey
+		want string
+	}{
+		{
+			name: "by id",
+			keys: mustSongOrder(t),
+			want: "((s.id) > ($2::bigint))",
+		},
+		{
+			name: "all descending",
+			keys: mustSongOrder(t, models.SortField{Field: "song_name", Desc: true}, models.SortField{Field: "id", Desc: true}),
+			want: "((s.song_name, s.id) < ($2::text, $3::bigint))",
+		},
+		{
+			name: "mixed directions",
+			keys: mustSongOrder(t, models.SortField{Field: "release_date", Desc: true}),
+			want: "((COALESCE(s.release_date, 'infinity'::date) < $2::date) OR " +
+				"(COALESCE(s.release_date, 'infinity'::date) = $2::date AND s.id > $3::bigint))",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c conditions
+			c.add("s.tenant_id = $%[1]d", "default")
+			c.after(tt.keys, make([]string, len(tt.keys)))
+
+			if got := c.list[len(c.list)-1]; got != tt.want {
+				t.Errorf("after = %s, want %s", got, tt.want)
+			}
+			if len(c.args) != len(tt.keys)+1 {
+				t.Errorf("after bound %d values, want %d", len(c.args)-1, len(tt.keys))
+			}
+		})
+	}
+}
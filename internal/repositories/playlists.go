@@ -0,0 +1,368 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nongrata2/musiclib/internal/models"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+func (db *DB) GetPlaylists(ctx context.Context) ([]models.Playlist, error) {
+	log := db.logger(ctx)
+	log.Debug("started getting playlist list DB")
+	playlists := []models.Playlist{}
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT p.id, p.name, COUNT(i.song_id), p.created_at
+        FROM playlists p
+        LEFT JOIN playlist_items i ON i.playlist_id = p.id
+        WHERE p.tenant_id = $1
+        GROUP BY p.id
+        ORDER BY p.id
+    `
+
+	rows, err := db.conn.Query(ctx, query, tid)
+	if err != nil {
+		log.Error("failed to fetch playlists", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var playlist models.Playlist
+		if err := rows.Scan(&playlist.ID, &playlist.Name, &playlist.SongCount, &playlist.CreatedAt); err != nil {
+			log.Error("failed to scan playlist row", "error", err)
+			return nil, dbError(err, nil, nil)
+		}
+		playlists = append(playlists, playlist)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("ended getting playlist list DB")
+	return playlists, nil
+}
+
+// GetPlaylist returns the playlist with its songs in playlist order.
+func (db *DB) GetPlaylist(ctx context.Context, id int) (*models.Playlist, error) {
+	log := db.logger(ctx)
+	log.Debug("started getting playlist DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT id, name, created_at
+        FROM playlists
+        WHERE tenant_id = $1 AND id = $2
+    `
+
+	playlist := models.Playlist{Songs: []models.PlaylistItem{}}
+	err = db.conn.QueryRow(ctx, query, tid, id).Scan(&playlist.ID, &playlist.Name, &playlist.CreatedAt)
+	if err != nil {
+		log.Error("failed to get playlist", "id", id, "error", err)
+		return nil, dbError(err, errors.PlaylistNotFoundErr, nil)
+	}
+
+	// stored positions may have gaps, clients see them numbered from 1
+	query = `
+        SELECT row_number() OVER (ORDER BY i.position), i.added_at, ` + songColumns + `
+        FROM playlist_items i
+        JOIN songs s ON s.id = i.song_id
+        JOIN groups g ON g.id = s.group_id
+        WHERE i.tenant_id = $1 AND i.playlist_id = $2
+        ORDER BY i.position
+    `
+
+	rows, err := db.conn.Query(ctx, query, tid, id)
+	if err != nil {
+		log.Error("failed to fetch playlist songs", "id", id, "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.PlaylistItem
+		dest := append([]any{&item.Position, &item.AddedAt}, songFields(&item.Song)...)
+		if err := rows.Scan(dest...); err != nil {
+			log.Error("failed to scan playlist item row", "error", err)
+			return nil, dbError(err, nil, nil)
+		}
+		playlist.Songs = append(playlist.Songs, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+	playlist.SongCount = len(playlist.Songs)
+
+	log.Debug("ended getting playlist DB")
+	return &playlist, nil
+}
+
+func (db *DB) AddPlaylist(ctx context.Context, name string) (*models.Playlist, error) {
+	log := db.logger(ctx)
+	log.Debug("started adding playlist DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        INSERT INTO playlists (tenant_id, name)
+        VALUES ($1, $2)
+        RETURNING id, name, created_at
+    `
+
+	var playlist models.Playlist
+	err = db.conn.QueryRow(ctx, query, tid, name).Scan(&playlist.ID, &playlist.Name, &playlist.CreatedAt)
+	if err != nil {
+		log.Error("failed to add playlist", "name", name, "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("ended adding playlist DB")
+	return &playlist, nil
+}
+
+func (db *DB) UpdatePlaylist(ctx context.Context, id int, name string) (*models.Playlist, error) {
+	log := db.logger(ctx)
+	log.Debug("started updating playlist DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        UPDATE playlists
+        SET name = $1
+        WHERE tenant_id = $2 AND id = $3
+        RETURNING id, name, (SELECT COUNT(*) FROM playlist_items WHERE tenant_id = $2 AND playlist_id = $3), created_at
+    `
+
+	var playlist models.Playlist
+	err = db.conn.QueryRow(ctx, query, name, tid, id).Scan(&playlist.ID, &playlist.Name, &playlist.SongCount, &playlist.CreatedAt)
+	if err != nil {
+		log.Error("failed to update playlist", "id", id, "name", name, "error", err)
+		return nil, dbError(err, errors.PlaylistNotFoundErr, nil)
+	}
+
+	log.Debug("ended updating playlist DB")
+	return &playlist, nil
+}
+
+func (db *DB) DeletePlaylist(ctx context.Context, id int) error {
+	log := db.logger(ctx)
+	log.Debug("started deleting playlist DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM playlists WHERE tenant_id = $1 AND id = $2`
+
+	result, err := db.conn.Exec(ctx, query, tid, id)
+	if err != nil {
+		log.Error("failed to delete playlist", "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	if result.RowsAffected() == 0 {
+		log.Warn("no playlist found with the given id", "id", id)
+		return errors.PlaylistNotFoundErr
+	}
+
+	log.Debug("ended deleting playlist DB")
+	return nil
+}
+
+// AddPlaylistSong puts the song at the given 1-based position of the
+// playlist, or at its end if position is 0.
+func (db *DB) AddPlaylistSong(ctx context.Context, playlistID, songID, position int) error {
+	log := db.logger(ctx)
+	log.Debug("started adding song to playlist DB")
+
+	err := db.changePlaylist(ctx, playlistID, func(tx pgx.Tx, tid string, songIDs []int) ([]int, error) {
+		order, err := insertAt(songIDs, songID, position)
+		if err != nil {
+			return nil, err
+		}
+
+		query := `
+            INSERT INTO playlist_items (tenant_id, playlist_id, song_id, position)
+            VALUES ($1, $2, $3, (
+                SELECT COALESCE(MAX(position), 0) + 1 FROM playlist_items WHERE tenant_id = $1 AND playlist_id = $2
+            ))
+        `
+		if _, err := tx.Exec(ctx, query, tid, playlistID, songID); err != nil {
+			log.Error("failed to add song to playlist", "playlist_id", playlistID, "song_id", songID, "error", err)
+			return nil, dbError(err, nil, errors.SongInPlaylistErr)
+		}
+		return order, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Debug("ended adding song to playlist DB")
+	return nil
+}
+
+// MovePlaylistSong moves the song to the given 1-based position of the playlist.
+func (db *DB) MovePlaylistSong(ctx context.Context, playlistID, songID, position int) error {
+	log := db.logger(ctx)
+	log.Debug("started moving song in playlist DB")
+
+	err := db.changePlaylist(ctx, playlistID, func(_ pgx.Tx, _ string, songIDs []int) ([]int, error) {
+		return moveTo(songIDs, songID, position)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Debug("ended moving song in playlist DB")
+	return nil
+}
+
+// insertAt returns the songs of a playlist in their order after the song is
+// added at the given 1-based position, or at the end if position is 0. It
+// returns nil when the song is added at the end, where it is inserted
+// anyway, so nothing needs renumbering.
+func insertAt(songIDs []int, songID, position int) ([]int, error) {
+	if position < 0 || position > len(songIDs)+1 {
+		return nil, errors.InvalidPositionErr
+	}
+	if position == 0 || position == len(songIDs)+1 {
+		return nil, nil
+	}
+	return slices.Insert(slices.Clip(songIDs), position-1, songID), nil
+}
+
+// moveTo returns the songs of a playlist in their order after the song is
+// moved to the given 1-based position.
+func moveTo(songIDs []int, songID, position int) ([]int, error) {
+	current := slices.Index(songIDs, songID)
+	if current < 0 {
+		return nil, errors.SongNotInPlaylistErr
+	}
+	if position < 1 || position > len(songIDs) {
+		return nil, errors.InvalidPositionErr
+	}
+
+	order := slices.Delete(slices.Clone(songIDs), current, current+1)
+	return slices.Insert(order, position-1, songID), nil
+}
+
+func (db *DB) RemovePlaylistSong(ctx context.Context, playlistID, songID int) error {
+	log := db.logger(ctx)
+	log.Debug("started removing song from playlist DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        WITH removed AS (
+            DELETE FROM playlist_items
+            WHERE tenant_id = $1 AND playlist_id = $2 AND song_id = $3
+            RETURNING song_id
+        )
+        SELECT EXISTS (SELECT 1 FROM playlists WHERE tenant_id = $1 AND id = $2), EXISTS (SELECT 1 FROM removed)
+    `
+
+	var playlistExists, removed bool
+	if err := db.conn.QueryRow(ctx, query, tid, playlistID, songID).Scan(&playlistExists, &removed); err != nil {
+		log.Error("failed to remove song from playlist", "playlist_id", playlistID, "song_id", songID, "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	switch {
+	case !playlistExists:
+		return errors.PlaylistNotFoundErr
+	case !removed:
+		return errors.SongNotInPlaylistErr
+	}
+
+	log.Debug("ended removing song from playlist DB")
+	return nil
+}
+
+// changePlaylist runs change on the playlist's songs in playlist order,
+// with the playlist locked against concurrent changes. change is also given
+// the tenant of the playlist. If change returns a new order, the items are
+// renumbered to follow it.
+func (db *DB) changePlaylist(ctx context.Context, playlistID int, change func(tx pgx.Tx, tid string, songIDs []int) ([]int, error)) error {
+	log := db.logger(ctx)
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return dbError(err, nil, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT id FROM playlists WHERE tenant_id = $1 AND id = $2 FOR UPDATE`
+	if err := tx.QueryRow(ctx, query, tid, playlistID).Scan(&playlistID); err != nil {
+		log.Error("failed to lock playlist", "id", playlistID, "error", err)
+		return dbError(err, errors.PlaylistNotFoundErr, nil)
+	}
+
+	query = `SELECT song_id FROM playlist_items WHERE tenant_id = $1 AND playlist_id = $2 ORDER BY position`
+	rows, err := tx.Query(ctx, query, tid, playlistID)
+	if err != nil {
+		log.Error("failed to fetch playlist songs", "id", playlistID, "error", err)
+		return dbError(err, nil, nil)
+	}
+	songIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		log.Error("failed to scan playlist songs", "id", playlistID, "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	order, err := change(tx, tid, songIDs)
+	if err != nil {
+		return err
+	}
+
+	if order != nil {
+		query = `
+            UPDATE playlist_items i
+            SET position = o.position
+            FROM unnest($3::bigint[]) WITH ORDINALITY AS o(song_id, position)
+            WHERE i.tenant_id = $1 AND i.playlist_id = $2 AND i.song_id = o.song_id
+        `
+		if _, err := tx.Exec(ctx, query, tid, playlistID, order); err != nil {
+			log.Error("failed to reorder playlist", "id", playlistID, "error", err)
+			return dbError(err, nil, nil)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	return nil
+}
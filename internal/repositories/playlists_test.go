@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	stdErrors "errors"
+	"slices"
+	"testing"
+
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+func TestInsertAt(t *testing.T) {
+	songIDs := []int{10, 20, 30}
+
+	tests := []struct {
+		name     string
+		position int
+		want     []int
+		wantErr  error
+	}{
+		{name: "first", position: 1, want: []int{5, 10, 20, 30}},
+		{name: "middle", position: 2, want: []int{10, 5, 20, 30}},
+		{name: "before the last", position: 3, want: []int{10, 20, 5, 30}},
+		{name: "after the last", position: 4},
+		{name: "end", position: 0},
+		{name: "past the end", position: 5, wantErr: errors.InvalidPositionErr},
+		{name: "negative", position: -1, wantErr: errors.InvalidPositionErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := insertAt(songIDs, 5, tt.position)
+			if !stdErrors.Is(err, tt.wantErr) {
+				t.Fatalf("insertAt error = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("insertAt = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(songIDs, []int{10, 20, 30}) {
+				t.Errorf("insertAt changed the songs to %v", songIDs)
+			}
+		})
+	}
+
+	t.Run("empty playlist", func(t *testing.T) {
+		if got, err := insertAt(nil, 5, 1); got != nil || err != nil {
+			t.Errorf("insertAt = %v, %v, want no renumbering", got, err)
+		}
+		if _, err := insertAt(nil, 5, 2); !stdErrors.Is(err, errors.InvalidPositionErr) {
+			t.Errorf("insertAt error = %v, want %v", err, errors.InvalidPositionErr)
+		}
+	})
+}
+
+func TestMoveTo(t *testing.T) {
+	songIDs := []int{10, 20, 30, 40}
+
+	tests := []struct {
+		name     string
+		songID   int
+		position int
+		want     []int
+		wantErr  error
+	}{
+		{name: "forward", songID: 10, position: 3, want: []int{20, 30, 10, 40}},
+		{name: "backward", songID: 40, position: 2, want: []int{10, 40, 20, 30}},
+		{name: "to the end", songID: 20, position: 4, want: []int{10, 30, 40, 20}},
+		{name: "to the start", songID: 30, position: 1, want: []int{30, 10, 20, 40}},
+		{name: "in place", songID: 20, position: 2, want: []int{10, 20, 30, 40}},
+		{name: "past the end", songID: 20, position: 5, wantErr: errors.InvalidPositionErr},
+		{name: "end", songID: 20, position: 0, wantErr: errors.InvalidPositionErr},
+		{name: "not in playlist", songID: 50, position: 1, wantErr: errors.SongNotInPlaylistErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := moveTo(songIDs, tt.songID, tt.position)
+			if !stdErrors.Is(err, tt.wantErr) {
+				t.Fatalf("moveTo error = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("moveTo = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(songIDs, []int{10, 20, 30, 40}) {
+				t.Errorf("moveTo changed the songs to %v", songIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"context"
+	"time"
+)
+
+// UseQuota counts a request of the client against the budget's quota for
+// the day and returns the number of requests made that day so far.
+func (db *DB) UseQuota(ctx context.Context, client, budget string, day time.Time) (int, error) {
+	log := db.logger(ctx)
+	query := `
+        INSERT INTO rate_limit_quotas (client, budget, day, used)
+        VALUES ($1, $2, $3, 1)
+        ON CONFLICT (client, budget, day) DO UPDATE
+        SET used = rate_limit_quotas.used + 1
+        RETURNING used
+    `
+
+	var used int
+	if err := db.conn.QueryRow(ctx, query, client, budget, day).Scan(&used); err != nil {
+		log.Error("failed to count quota", "client", client, "budget", budget, "error", err)
+		return 0, dbError(err, nil, nil)
+	}
+
+	return used, nil
+}
+
+// PruneQuotas deletes the counts of the days before day.
+func (db *DB) PruneQuotas(ctx context.Context, day time.Time) error {
+	log := db.logger(ctx)
+	log.Debug("started pruning quotas DB")
+
+	query := `DELETE FROM rate_limit_quotas WHERE day < $1`
+
+	result, err := db.conn.Exec(ctx, query, day)
+	if err != nil {
+		log.Error("failed to prune quotas", "error", err)
+		return dbError(err, nil, nil)
+	}
+
+	log.Debug("ended pruning quotas DB", "deleted", result.RowsAffected())
+	return nil
+}
@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/nongrata2/musiclib/internal/logging"
 	"github.com/nongrata2/musiclib/internal/models"
 	"github.com/nongrata2/musiclib/pkg/errors"
 )
@@ -18,27 +20,59 @@ import (
 type DB struct {
 	log  *slog.Logger
 	conn *pgxpool.Pool
+	// role is the database role queries run as, see UseRole
+	role string
+	// tracer records the spans of queries
+	tracer *queryTracer
+	// apiKeyUses holds when the use of each API key was last recorded
+	apiKeyUses sync.Map
 }
 
-func addCondition(conditions *[]string, args *[]any, field string, value any, index *int) {
-	switch v := value.(type) {
-	case string:
-		if v != "" {
-			*conditions = append(*conditions, fmt.Sprintf("%s = $%d", field, *index))
-			*args = append(*args, value)
-			*index++
-		}
-	case time.Time:
-		if !v.IsZero() {
-			*conditions = append(*conditions, fmt.Sprintf("%s = $%d", field, *index))
-			*args = append(*args, value)
-			*index++
-		}
+// logger returns the logger of the request ctx belongs to, if any, so that
+// queries are logged along with the request that made them.
+func (db *DB) logger(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, db.log)
+}
+
+// querier is implemented by both the pool and transactions.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// songColumns selects a song from songs s joined with groups g in the order songFields expects.
+const songColumns = "s.id, g.group_name, s.song_name, s.release_date, s.text, s.link, s.enrichment_status"
+
+// songReturning is songColumns for RETURNING clauses of statements on songs.
+const songReturning = "id, (SELECT group_name FROM groups WHERE id = songs.group_id), song_name, release_date, text, link, enrichment_status"
+
+// songFields returns scan destinations for songColumns and songReturning.
+func songFields(song *models.Song) []any {
+	return []any{
+		&song.ID,
+		&song.Group,
+		&song.Songname,
+		&song.ReleaseDate,
+		&song.Text,
+		&song.Link,
+		&song.EnrichmentStatus,
 	}
 }
 
 func New(log *slog.Logger, address string) (*DB, error) {
-	pool, err := pgxpool.New(context.Background(), address)
+	config, err := pgxpool.ParseConfig(address)
+	if err != nil {
+		log.Error("invalid database address", "error", err)
+		return nil, err
+	}
+
+	db := &DB{log: log, tracer: newQueryTracer()}
+	config.BeforeAcquire = db.setTenant
+	config.AfterRelease = db.resetTenant
+	config.ConnConfig.Tracer = db.tracer
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		log.Error("connection problem", "address", address, "error", err)
 		return nil, err
@@ -51,163 +85,372 @@ func New(log *slog.Logger, address string) (*DB, error) {
 
 	log.Info("successfully connected to database", "address", address)
 
-	return &DB{
-		log:  log,
-		conn: pool,
-	}, nil
+	db.conn = pool
+	return db, nil
 }
 
-func (db *DB) Add(ctx context.Context, song models.Song) error {
+// TraceStatements makes the spans of queries record their statements whole
+// rather than cut short. Statements do not hold the query arguments, but
+// long ones make spans large.
+func (db *DB) TraceStatements(full bool) {
+	db.tracer.fullStatements.Store(full)
+}
 
-	db.log.Debug("started adding song DB")
+// groupID returns the id of the group with the given name, creating the group if needed.
+func (db *DB) groupID(ctx context.Context, q querier, name string) (int, error) {
+	log := db.logger(ctx)
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
 
 	var groupID int
 	query := `
-        INSERT INTO groups (group_name)
-        VALUES ($1)
-        ON CONFLICT (group_name) DO NOTHING
+        INSERT INTO groups (tenant_id, group_name)
+        VALUES ($1, $2)
+        ON CONFLICT (tenant_id, group_name) DO NOTHING
         RETURNING id
     `
-	err := db.conn.QueryRow(ctx, query, song.Group).Scan(&groupID)
+	err = q.QueryRow(ctx, query, tid, name).Scan(&groupID)
 	if err == pgx.ErrNoRows {
-		query = `SELECT id FROM groups WHERE group_name = $1`
-		err = db.conn.QueryRow(ctx, query, song.Group).Scan(&groupID)
+		query = `SELECT id FROM groups WHERE tenant_id = $1 AND group_name = $2`
+		err = q.QueryRow(ctx, query, tid, name).Scan(&groupID)
 		if err != nil {
-			db.log.Error("failed to get group ID", "error", err)
-			return err
+			log.Error("failed to get group ID", "error", err)
+			return 0, dbError(err, nil, nil)
 		}
 	} else if err != nil {
-		db.log.Error("failed to add or check group", "error", err)
-		return err
+		log.Error("failed to add or check group", "error", err)
+		return 0, dbError(err, nil, nil)
+	}
+	return groupID, nil
+}
+
+// FindSong returns the song with the given name in the given group. Song
+// names are compared case-insensitively, the same way the unique index does.
+func (db *DB) FindSong(ctx context.Context, group, songName string) (*models.Song, error) {
+	log := db.logger(ctx)
+	log.Debug("started finding song DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	query = `
-        INSERT INTO songs (group_id, song_name, release_date, text, link)
-        VALUES ($1, $2, $3, $4, $5)
+	query := `
+        SELECT ` + songColumns + `
+        FROM songs s
+        JOIN groups g ON s.group_id = g.id
+        WHERE s.tenant_id = $1 AND g.group_name = $2 AND lower(s.song_name) = lower($3)
     `
-	_, err = db.conn.Exec(ctx, query,
+
+	var song models.Song
+	err = db.conn.QueryRow(ctx, query, tid, group, songName).Scan(songFields(&song)...)
+	if err != nil {
+		log.Debug("song not found", "group_name", group, "song_name", songName, "error", err)
+		return nil, dbError(err, errors.NotFoundErr, nil)
+	}
+
+	log.Debug("ended finding song DB")
+	return &song, nil
+}
+
+// songConflict attaches the id of the already existing song to a conflict error.
+func (db *DB) songConflict(ctx context.Context, err error, group, songName string) error {
+	if !stdErrors.Is(err, errors.SongExistsErr) {
+		return err
+	}
+
+	existing, findErr := db.FindSong(ctx, group, songName)
+	if findErr != nil {
+		return err
+	}
+
+	return errors.SongExistsErr.WithDetails(map[string]any{"song_id": existing.ID}).Wrap(stdErrors.Unwrap(err))
+}
+
+// patchConflict is songConflict for a patch of the song with the given id.
+// The song clashes under the names set by the patch, or its current ones
+// where the patch leaves them unchanged.
+func (db *DB) patchConflict(ctx context.Context, err error, id int, patch models.SongPatch) error {
+	if !stdErrors.Is(err, errors.SongExistsErr) {
+		return err
+	}
+
+	if patch.Group == nil || patch.Songname == nil {
+		tid, tidErr := tenantID(ctx)
+		if tidErr != nil {
+			return err
+		}
+
+		var group, songName string
+		query := `
+            SELECT g.group_name, s.song_name
+            FROM songs s
+            JOIN groups g ON s.group_id = g.id
+            WHERE s.tenant_id = $1 AND s.id = $2
+        `
+		if findErr := db.conn.QueryRow(ctx, query, tid, id).Scan(&group, &songName); findErr != nil {
+			return err
+		}
+		if patch.Group == nil {
+			patch.Group = &group
+		}
+		if patch.Songname == nil {
+			patch.Songname = &songName
+		}
+	}
+
+	return db.songConflict(ctx, err, *patch.Group, *patch.Songname)
+}
+
+// Add saves the song and returns it as stored.
+func (db *DB) Add(ctx context.Context, song models.Song) (*models.Song, error) {
+	log := db.logger(ctx)
+
+	log.Debug("started adding song DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the group is only added along with the song
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	groupID, err := db.groupID(ctx, tx, song.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        INSERT INTO songs (tenant_id, group_id, song_name, release_date, text, link, enrichment_status)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING ` + songReturning
+
+	var addedSong models.Song
+	err = tx.QueryRow(ctx, query,
+		tid,
 		groupID,
 		song.Songname,
 		song.ReleaseDate,
 		song.Text,
 		song.Link,
-	)
+		song.EnrichmentStatus,
+	).Scan(songFields(&addedSong)...)
 
 	if err != nil {
-		db.log.Error("failed to add song", "error", err)
-		return err
+		log.Error("failed to add song", "error", err)
+		return nil, db.songConflict(ctx, dbError(err, nil, errors.SongExistsErr), song.Group, song.Songname)
 	}
-	db.log.Debug("ended adding song DB")
 
-	return nil
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("ended adding song DB", "id", addedSong.ID)
+
+	return &addedSong, nil
 }
 
-func (db *DB) GetSongs(ctx context.Context, filters models.SongFilter, page, limit int) ([]models.Song, error) {
-	db.log.Debug("started getting song list DB")
-	var songs []models.Song
-	var maxLimit = 20
+// GetSongs returns up to req.Limit songs matching the filters in the
+// requested order, starting right after the position encoded in req.Cursor
+// (from the beginning if it is empty). The matching songs are only counted
+// for the first page, the count would cost every next page a full scan.
+func (db *DB) GetSongs(ctx context.Context, filters models.SongFilter, req models.PageRequest) (*models.SongPage, error) {
+	log := db.logger(ctx)
+	log.Debug("started getting song list DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conds, queryArg := songConditions(filters)
+	conds.add("s.tenant_id = $%[1]d", tid)
+
+	keys, err := songOrder(req.Sort, queryArg)
+	if err != nil {
+		log.Error("invalid sort", "sort", req.Sort, "error", err)
+		return nil, err
+	}
+
+	page := &models.SongPage{Items: []models.Song{}}
+
+	if req.Cursor == "" {
+		countQuery := `
+            SELECT COUNT(*)
+            FROM songs s
+            JOIN groups g ON s.group_id = g.id
+        ` + conds.where()
+
+		var total int
+		if err := db.conn.QueryRow(ctx, countQuery, conds.args...).Scan(&total); err != nil {
+			log.Error("failed to count songs", "error", err)
+			return nil, dbError(err, nil, nil)
+		}
+		page.Total = &total
+	} else {
+		values, err := decodeCursor(req.Cursor, keys)
+		if err != nil {
+			log.Error("failed to decode cursor", "cursor", req.Cursor, "error", err)
+			return nil, err
+		}
+		conds.after(keys, values)
+	}
 
 	query := `
-        SELECT s.id, g.group_name, s.song_name, s.release_date, s.text, s.link
+        SELECT ` + songColumns + keyColumns(keys) + `
         FROM songs s
         JOIN groups g ON s.group_id = g.id
-    `
-
-	var conditions []string
-	var args []any
-	i := 1
+    ` + conds.where() + orderBy(keys) + fmt.Sprintf(" LIMIT %d", req.Limit+1)
 
-	addCondition(&conditions, &args, "group_name", filters.Group, &i)
-	addCondition(&conditions, &args, "song_name", filters.Songname, &i)
-	addCondition(&conditions, &args, "release_date", filters.ReleaseDate, &i)
-	addCondition(&conditions, &args, "text", filters.Text, &i)
-	addCondition(&conditions, &args, "link", filters.Link, &i)
+	log.Debug("executing query", "query", query, "args", conds.args)
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	rows, err := db.conn.Query(ctx, query, conds.args...)
+	if err != nil {
+		log.Error("failed to fetch songs", "error", err)
+		return nil, dbError(err, nil, nil)
 	}
+	defer rows.Close()
 
-	if limit != 0 && page != 0 {
-		if limit > maxLimit {
-			limit = maxLimit
+	var lastValues []string
+	for rows.Next() {
+		// one extra row is fetched to find out whether there is a next page
+		if len(page.Items) == req.Limit {
+			page.NextCursor = encodeCursor(keys, lastValues)
+			break
+		}
+
+		var song models.Song
+		values := make([]string, len(keys))
+		dest := songFields(&song)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			log.Error("failed to scan song row", "error", err)
+			return nil, dbError(err, nil, nil)
 		}
-		offset := (page - 1) * limit
-		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+		page.Items = append(page.Items, song)
+		lastValues = values
 	}
 
-	db.log.Debug("executing query", "query", query, "args", args)
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("ended getting song list DB")
+	return page, nil
+}
+
+// ExportSongs calls fn for every song matching the filters, in id order.
+// Rows are read from the connection only as fn consumes them, so memory use
+// does not grow with the library. An error returned by fn stops the export.
+func (db *DB) ExportSongs(ctx context.Context, filters models.SongFilter, fn func(models.Song) error) error {
+	log := db.logger(ctx)
+	log.Debug("started exporting songs DB")
 
-	rows, err := db.conn.Query(ctx, query, args...)
+	tid, err := tenantID(ctx)
 	if err != nil {
-		db.log.Error("failed to fetch songs", "error", err)
-		return nil, err
+		return err
+	}
+
+	conds, _ := songConditions(filters)
+	conds.add("s.tenant_id = $%[1]d", tid)
+
+	query := `
+        SELECT ` + songColumns + `
+        FROM songs s
+        JOIN groups g ON s.group_id = g.id
+    ` + conds.where() + orderBy([]sortKey{idSortKey})
+
+	log.Debug("executing query", "query", query, "args", conds.args)
+
+	rows, err := db.conn.Query(ctx, query, conds.args...)
+	if err != nil {
+		log.Error("failed to fetch songs", "error", err)
+		return dbError(err, nil, nil)
 	}
 	defer rows.Close()
 
+	count := 0
 	for rows.Next() {
 		var song models.Song
-		err := rows.Scan(
-			&song.ID,
-			&song.Group,
-			&song.Songname,
-			&song.ReleaseDate,
-			&song.Text,
-			&song.Link,
-		)
-		if err != nil {
-			db.log.Error("failed to scan song row", "error", err)
-			return nil, err
+		if err := rows.Scan(songFields(&song)...); err != nil {
+			log.Error("failed to scan song row", "error", err)
+			return dbError(err, nil, nil)
 		}
-		songs = append(songs, song)
+
+		if err := fn(song); err != nil {
+			return err
+		}
+		count++
 	}
 
 	if err := rows.Err(); err != nil {
-		db.log.Error("error while iterating over rows", "error", err)
-		return nil, err
+		log.Error("error while iterating over rows", "error", err)
+		return dbError(err, nil, nil)
 	}
 
-	db.log.Debug("ended getting song list DB")
-	return songs, nil
+	log.Debug("ended exporting songs DB", "count", count)
+	return nil
 }
 
-func (db *DB) Delete(ctx context.Context, songID string) error {
-	db.log.Debug("started deleting song DB")
-
-	query := `DELETE FROM songs WHERE id = $1`
+func (db *DB) Delete(ctx context.Context, songID int) error {
+	log := db.logger(ctx)
+	log.Debug("started deleting song DB")
 
-	result, err := db.conn.Exec(ctx, query, songID)
+	tid, err := tenantID(ctx)
 	if err != nil {
-		db.log.Error("failed to delete song", "error", err)
 		return err
 	}
 
+	query := `DELETE FROM songs WHERE tenant_id = $1 AND id = $2`
+
+	result, err := db.conn.Exec(ctx, query, tid, songID)
+	if err != nil {
+		log.Error("failed to delete song", "error", err)
+		return dbError(err, nil, nil)
+	}
+
 	rowsAffected := result.RowsAffected()
 
 	if rowsAffected == 0 {
-		db.log.Warn("no song found with the given id", "id", songID)
+		log.Warn("no song found with the given id", "id", songID)
 		return errors.NotFoundErr
 	}
-	db.log.Debug("ended deleting song DB")
+	log.Debug("ended deleting song DB")
 	return nil
 }
 
-func (db *DB) GetLyrics(ctx context.Context, songID string, page, limit int) (string, error) {
-	db.log.Debug("started getting lyrics DB")
+func (db *DB) GetLyrics(ctx context.Context, songID int, page, limit int) (string, error) {
+	log := db.logger(ctx)
+	log.Debug("started getting lyrics DB")
 	var songLyrics string
 
-	query := `SELECT text FROM songs WHERE id = $1`
-	err := db.conn.QueryRow(ctx, query, songID).Scan(&songLyrics)
+	tid, err := tenantID(ctx)
 	if err != nil {
-		if stdErrors.Is(err, pgx.ErrNoRows) {
-			db.log.Error("no song found with the given ID", "id", songID)
-			return "", errors.NotFoundErr
-		}
-		db.log.Error("failed to get lyrics of the song", "error", err)
 		return "", err
 	}
 
+	query := `SELECT text FROM songs WHERE tenant_id = $1 AND id = $2`
+	err = db.conn.QueryRow(ctx, query, tid, songID).Scan(&songLyrics)
+	if err != nil {
+		log.Error("failed to get lyrics of the song", "id", songID, "error", err)
+		return "", dbError(err, errors.NotFoundErr, nil)
+	}
+
 	if limit == 0 || page == 0 {
-		db.log.Debug("pagination not used, returning full lyrics")
+		log.Debug("pagination not used, returning full lyrics")
 		return songLyrics, nil
 	}
 
@@ -226,70 +469,157 @@ func (db *DB) GetLyrics(ctx context.Context, songID string, page, limit int) (st
 	paginatedVerses := verses[start:end]
 	result := strings.Join(paginatedVerses, "\n\n")
 
-	db.log.Debug("ended getting lyrics DB")
+	log.Debug("ended getting lyrics DB")
 	return result, nil
 }
 
+// Update replaces the song. Its enrichment status is kept unless the new one is set.
 func (db *DB) Update(ctx context.Context, id int, song models.Song) (*models.Song, error) {
-	db.log.Debug("started updating song DB")
+	log := db.logger(ctx)
+	log.Debug("started updating song DB")
 
-	var groupID int
-	query := `
-        INSERT INTO groups (group_name)
-        VALUES ($1)
-        ON CONFLICT (group_name) DO NOTHING
-        RETURNING id
-    `
-	err := db.conn.QueryRow(ctx, query, song.Group).Scan(&groupID)
-	if err == pgx.ErrNoRows {
-		query = `SELECT id FROM groups WHERE group_name = $1`
-		err = db.conn.QueryRow(ctx, query, song.Group).Scan(&groupID)
-		if err != nil {
-			db.log.Error("failed to get group ID", "error", err)
-			return nil, err
-		}
-	} else if err != nil {
-		db.log.Error("failed to add or check group", "error", err)
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the group is only added along with the song
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	groupID, err := db.groupID(ctx, tx, song.Group)
+	if err != nil {
 		return nil, err
 	}
 
-	query = `
+	query := `
         UPDATE songs
         SET group_id = $1,
             song_name = $2,
             release_date = $3,
             text = $4,
-            link = $5
-        WHERE id = $6
-        RETURNING id, (SELECT group_name FROM groups WHERE id = $1), song_name, release_date, text, link
-    `
+            link = $5,
+            enrichment_status = COALESCE(NULLIF($6, ''), enrichment_status)
+        WHERE tenant_id = $7 AND id = $8
+        RETURNING ` + songReturning
 
 	var updatedSong models.Song
-	err = db.conn.QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		groupID,
 		song.Songname,
 		song.ReleaseDate,
 		song.Text,
 		song.Link,
+		song.EnrichmentStatus,
+		tid,
 		id,
-	).Scan(
-		&updatedSong.ID,
-		&updatedSong.Group,
-		&updatedSong.Songname,
-		&updatedSong.ReleaseDate,
-		&updatedSong.Text,
-		&updatedSong.Link,
-	)
+	).Scan(songFields(&updatedSong)...)
 
 	if err != nil {
-		if stdErrors.Is(err, pgx.ErrNoRows) {
-			db.log.Error("no song found with the given ID", "id", id)
-			return nil, errors.NotFoundErr
-		}
-		db.log.Error("failed to update song", "error", err)
-		return nil, err
+		log.Error("failed to update song", "id", id, "error", err)
+		return nil, db.songConflict(ctx, dbError(err, errors.NotFoundErr, errors.SongExistsErr), song.Group, song.Songname)
 	}
 
-	db.log.Debug("end updating song DB")
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("end updating song DB")
 	return &updatedSong, nil
 }
+
+// Patch updates only the fields set in the patch and returns the resulting song.
+func (db *DB) Patch(ctx context.Context, id int, patch models.SongPatch) (*models.Song, error) {
+	log := db.logger(ctx)
+	log.Debug("started patching song DB")
+
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the group is only added along with the song
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	var sets []string
+	var args []any
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if patch.Group != nil {
+		groupID, err := db.groupID(ctx, tx, *patch.Group)
+		if err != nil {
+			return nil, err
+		}
+		set("group_id", groupID)
+	}
+	if patch.Songname != nil {
+		set("song_name", *patch.Songname)
+	}
+	if patch.ReleaseDate != nil {
+		if patch.ReleaseDate.IsZero() {
+			set("release_date", nil)
+		} else {
+			set("release_date", *patch.ReleaseDate)
+		}
+	}
+	if patch.Text != nil {
+		set("text", *patch.Text)
+	}
+	if patch.Link != nil {
+		set("link", *patch.Link)
+	}
+
+	var query string
+	if len(sets) == 0 {
+		// an empty patch changes nothing, the song is returned as is
+		query = `
+            SELECT ` + songColumns + `
+            FROM songs s
+            JOIN groups g ON s.group_id = g.id
+            WHERE s.tenant_id = $1 AND s.id = $2
+        `
+	} else {
+		query = fmt.Sprintf(`
+            UPDATE songs
+            SET %s
+            WHERE tenant_id = $%d AND id = $%d
+            RETURNING %s
+        `, strings.Join(sets, ", "), len(args)+1, len(args)+2, songReturning)
+	}
+	args = append(args, tid, id)
+
+	log.Debug("executing query", "query", query, "args", args)
+
+	var patchedSong models.Song
+	err = tx.QueryRow(ctx, query, args...).Scan(songFields(&patchedSong)...)
+	if err != nil {
+		log.Error("failed to patch song", "id", id, "error", err)
+		return nil, db.patchConflict(ctx, dbError(err, errors.NotFoundErr, errors.SongExistsErr), id, patch)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, dbError(err, nil, nil)
+	}
+
+	log.Debug("ended patching song DB")
+	return &patchedSong, nil
+}
+
+// Stat returns the statistics of the connection pool.
+func (db *DB) Stat() *pgxpool.Stat {
+	return db.conn.Stat()
+}
@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nongrata2/musiclib/internal/tenant"
+	"github.com/nongrata2/musiclib/pkg/errors"
+)
+
+// allTenantsKey marks contexts of queries that act for every tenant at once.
+type allTenantsKey struct{}
+
+func allTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allTenantsKey{}, true)
+}
+
+// tenantID returns the tenant ctx acts for. Queries filter on it themselves
+// too, so that isolation does not rest on row level security alone.
+func tenantID(ctx context.Context) (string, error) {
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", errors.Internal(stdErrors.New("query without a tenant"))
+	}
+	return id, nil
+}
+
+// UseRole makes every query after it run as the database role, which must
+// be subject to row level security. The role the service logs in as keeps
+// owning the schema and running the migrations. An empty role keeps the
+// login role, which then has to be subject to row level security itself.
+func (db *DB) UseRole(ctx context.Context, role string) error {
+	db.role = role
+	return db.checkRowSecurity(ctx)
+}
+
+// connScope is what a connection is scoped to: the settings the row level
+// security policies read and the role queries run as.
+type connScope struct {
+	tenant string
+	all    string
+	role   string
+}
+
+// connScopeKey keeps the scope of a connection in its custom data, so that
+// it is only set when it changes.
+const connScopeKey = "musiclib.scope"
+
+// resetTimeout bounds resetting a released connection.
+const resetTimeout = 5 * time.Second
+
+// scope returns the scope queries made with ctx need. Without a tenant it
+// is the scope of idle connections.
+func (db *DB) scope(ctx context.Context) connScope {
+	s := connScope{all: "off", role: db.role}
+	s.tenant, _ = tenant.FromContext(ctx)
+	if ctx.Value(allTenantsKey{}) != nil {
+		s.all = "on"
+	}
+	if s.role == "" {
+		s.role = "none"
+	}
+	return s
+}
+
+// setTenant scopes a connection to the tenant of ctx before the pool hands
+// it out and switches it to the application role. The row level security
+// policies then show only the tenant's rows, and inserted rows take its id.
+// A connection without a tenant sees nothing and cannot insert. Idle
+// connections are already scoped to no tenant, so acquiring one for a query
+// without a tenant, such as a health check, costs no round trip.
+func (db *DB) setTenant(ctx context.Context, conn *pgx.Conn) bool {
+	if err := db.setScope(ctx, conn, db.scope(ctx)); err != nil {
+		db.logger(ctx).Error("failed to set tenant of connection", "error", err)
+		return false
+	}
+	return true
+}
+
+// resetTenant scopes a released connection back to no tenant, so that idle
+// connections never carry the tenant of the last request. The pool calls it
+// in the background; a connection that cannot be reset is closed.
+func (db *DB) resetTenant(conn *pgx.Conn) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), resetTimeout)
+	defer cancel()
+
+	if err := db.setScope(ctx, conn, db.scope(ctx)); err != nil {
+		db.log.Error("failed to reset tenant of connection", "error", err)
+		return false
+	}
+	return true
+}
+
+func (db *DB) setScope(ctx context.Context, conn *pgx.Conn, s connScope) error {
+	data := conn.PgConn().CustomData()
+	if current, ok := data[connScopeKey].(connScope); ok && current == s {
+		return nil
+	}
+
+	// the scope is unknown until the query succeeds
+	delete(data, connScopeKey)
+	query := `
+        SELECT set_config('musiclib.tenant_id', $1, false),
+               set_config('musiclib.all_tenants', $2, false),
+               set_config('role', $3, false)
+    `
+	if _, err := conn.Exec(ctx, query, s.tenant, s.all, s.role); err != nil {
+		return fmt.Errorf("tenant %q, role %s: %w", s.tenant, s.role, err)
+	}
+	data[connScopeKey] = s
+	return nil
+}
+
+// checkRowSecurity fails if queries run as a role that is not subject to
+// row level security, in which case tenants would see each other's libraries.
+func (db *DB) checkRowSecurity(ctx context.Context) error {
+	var role string
+	var bypass bool
+	query := `SELECT rolname, rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user`
+	if err := db.conn.QueryRow(ctx, query).Scan(&role, &bypass); err != nil {
+		return fmt.Errorf("failed to check database role: %w", err)
+	}
+	if bypass {
+		return fmt.Errorf("database role %s bypasses row level security, tenants would not be isolated", role)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxTracedStatementLength caps the statements recorded in spans unless
+// full statements are asked for, see DB.TraceStatements.
+const maxTracedStatementLength = 256
+
+// queryTracer records a span for every query and batch, as a child of the
+// span of the request making it. Spans are named after the operation; the
+// statement is recorded cut to maxTracedStatementLength.
+type queryTracer struct {
+	tracer trace.Tracer
+	// fullStatements records statements whole
+	fullStatements atomic.Bool
+}
+
+func newQueryTracer() *queryTracer {
+	return &queryTracer{tracer: otel.Tracer("github.com/nongrata2/musiclib/internal/repositories")}
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation := queryOperation(data.SQL)
+	ctx, _ = t.tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(t.statement(data.SQL)),
+		))
+	return ctx
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	endSpan(trace.SpanFromContext(ctx), data.CommandTag.RowsAffected(), data.Err)
+}
+
+func (t *queryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	ctx, _ = t.tracer.Start(ctx, "BATCH",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName("BATCH"),
+			attribute.Int("db.batch.size", data.Batch.Len()),
+		))
+	return ctx
+}
+
+func (t *queryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	// the statements of a batch are usually the same, an event each is enough
+	attrs := []attribute.KeyValue{semconv.DBQueryText(t.statement(data.SQL))}
+	if data.Err != nil {
+		attrs = append(attrs, attribute.String("error", data.Err.Error()))
+	}
+	trace.SpanFromContext(ctx).AddEvent("query", trace.WithAttributes(attrs...))
+}
+
+func (t *queryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	endSpan(trace.SpanFromContext(ctx), -1, data.Err)
+}
+
+func endSpan(span trace.Span, rowsAffected int64, err error) {
+	if rowsAffected >= 0 {
+		span.SetAttributes(attribute.Int64("db.rows_affected", rowsAffected))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// statement returns the text of a statement to record.
+func (t *queryTracer) statement(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if t.fullStatements.Load() || len(sql) <= maxTracedStatementLength {
+		return sql
+	}
+
+	end := maxTracedStatementLength
+	for end > 0 && !utf8.RuneStart(sql[end]) {
+		end--
+	}
+	return sql[:end] + "…"
+}
+
+// queryOperation returns the first keyword of a statement, such as SELECT.
+func queryOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
@@ -0,0 +1,33 @@
+// Package tenant carries the tenant a request acts for. Each tenant has a
+// library of its own: groups, songs, playlists and enrichment jobs of other
+// tenants are invisible to it.
+package tenant
+
+import (
+	"context"
+	"net/http"
+)
+
+// Default owns the data created before tenants existed and is used when
+// authentication is disabled.
+const Default = "default"
+
+type tenantKey struct{}
+
+// WithID returns a copy of ctx acting for the tenant.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext returns the tenant ctx acts for, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Handler makes every request to next act for the tenant.
+func Handler(id string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithID(r.Context(), id)))
+	})
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_songs_text_search;
+ALTER TABLE songs DROP COLUMN IF EXISTS text_search;
@@ -0,0 +1,6 @@
+ALTER TABLE songs
+    ADD COLUMN IF NOT EXISTS text_search tsvector GENERATED ALWAYS AS (
+        to_tsvector('russian', text) || to_tsvector('english', text)
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_songs_text_search ON songs USING GIN (text_search);
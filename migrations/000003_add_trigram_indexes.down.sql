@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_songs_release_date;
+DROP INDEX IF EXISTS idx_songs_link_trgm;
+DROP INDEX IF EXISTS idx_songs_song_name_trgm;
+DROP INDEX IF EXISTS idx_groups_group_name_trgm;
@@ -0,0 +1,6 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_groups_group_name_trgm ON groups USING GIN (group_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_songs_song_name_trgm ON songs USING GIN (song_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_songs_link_trgm ON songs USING GIN (link gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_songs_release_date ON songs (release_date);
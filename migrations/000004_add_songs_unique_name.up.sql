@@ -0,0 +1,12 @@
+-- Songs added twice before names were unique are kept, but every copy after
+-- the first one is renamed after its id, so that the index can be built and
+-- the copies can be found and merged by hand.
+UPDATE songs s
+SET song_name = s.song_name || ' (duplicate ' || s.id || ')'
+FROM (
+    SELECT id, row_number() OVER (PARTITION BY group_id, lower(song_name) ORDER BY id) AS n
+    FROM songs
+) d
+WHERE s.id = d.id AND d.n > 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_songs_group_song_name_unique ON songs (group_id, lower(song_name));
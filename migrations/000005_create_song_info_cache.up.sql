@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS song_info_cache (
+    cache_key TEXT PRIMARY KEY,
+    release_date TIMESTAMPTZ,
+    text TEXT NOT NULL DEFAULT '',
+    link TEXT NOT NULL DEFAULT '',
+    not_found BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_song_info_cache_expires_at ON song_info_cache (expires_at);
@@ -0,0 +1,15 @@
+-- songs that were never enriched have no release date to restore, they
+-- have to be given one or removed by hand before rolling back
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM songs WHERE release_date IS NULL) THEN
+        RAISE EXCEPTION 'songs without a release date exist, set their release_date before rolling back';
+    END IF;
+END
+$$;
+
+DROP TABLE IF EXISTS enrichment_jobs;
+
+ALTER TABLE songs DROP COLUMN IF EXISTS enrichment_status;
+
+ALTER TABLE songs ALTER COLUMN release_date SET NOT NULL;
@@ -0,0 +1,20 @@
+ALTER TABLE songs ALTER COLUMN release_date DROP NOT NULL;
+
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS enrichment_status TEXT NOT NULL DEFAULT 'succeeded'
+    CHECK (enrichment_status IN ('pending', 'succeeded', 'failed'));
+
+CREATE TABLE IF NOT EXISTS enrichment_jobs (
+    id BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    song_id BIGINT NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'running', 'succeeded', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_run_at ON enrichment_jobs (run_at)
+    WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_song_id ON enrichment_jobs (song_id);
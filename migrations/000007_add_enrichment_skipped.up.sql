@@ -0,0 +1,3 @@
+ALTER TABLE songs DROP CONSTRAINT IF EXISTS songs_enrichment_status_check;
+ALTER TABLE songs ADD CONSTRAINT songs_enrichment_status_check
+    CHECK (enrichment_status IN ('pending', 'succeeded', 'failed', 'skipped'));
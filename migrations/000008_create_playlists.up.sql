@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS playlists (
+    id BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    name TEXT NOT NULL CHECK (name <> ''),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Positions only order the items and may have gaps. The unique constraint is
+-- deferrable, so that items can be renumbered with a single UPDATE.
+CREATE TABLE IF NOT EXISTS playlist_items (
+    playlist_id BIGINT NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+    song_id BIGINT NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (playlist_id, song_id),
+    CONSTRAINT playlist_items_position_key UNIQUE (playlist_id, position) DEFERRABLE INITIALLY IMMEDIATE
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlist_items_song_id ON playlist_items (song_id);
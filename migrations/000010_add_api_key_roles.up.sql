@@ -0,0 +1,4 @@
+-- keys created before roles existed could do everything, so they stay admins
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'admin'
+    CHECK (role IN ('reader', 'editor', 'admin'));
+ALTER TABLE api_keys ALTER COLUMN role SET DEFAULT 'reader';
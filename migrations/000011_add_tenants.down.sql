@@ -0,0 +1,46 @@
+DROP POLICY IF EXISTS tenant_isolation ON playlist_items;
+DROP POLICY IF EXISTS tenant_isolation ON playlists;
+DROP POLICY IF EXISTS tenant_isolation ON enrichment_jobs;
+DROP POLICY IF EXISTS tenant_isolation ON songs;
+DROP POLICY IF EXISTS tenant_isolation ON groups;
+
+ALTER TABLE playlist_items NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE playlists NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE enrichment_jobs NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE songs NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+ALTER TABLE groups NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY;
+
+ALTER TABLE playlist_items
+    DROP CONSTRAINT IF EXISTS playlist_items_playlist_id_fkey,
+    DROP CONSTRAINT IF EXISTS playlist_items_song_id_fkey,
+    ADD CONSTRAINT playlist_items_playlist_id_fkey FOREIGN KEY (playlist_id)
+        REFERENCES playlists (id) ON DELETE CASCADE,
+    ADD CONSTRAINT playlist_items_song_id_fkey FOREIGN KEY (song_id)
+        REFERENCES songs (id) ON DELETE CASCADE;
+
+ALTER TABLE enrichment_jobs
+    DROP CONSTRAINT IF EXISTS enrichment_jobs_song_id_fkey,
+    ADD CONSTRAINT enrichment_jobs_song_id_fkey FOREIGN KEY (song_id)
+        REFERENCES songs (id) ON DELETE CASCADE;
+
+ALTER TABLE songs
+    DROP CONSTRAINT IF EXISTS fk_group,
+    ADD CONSTRAINT fk_group FOREIGN KEY (group_id)
+        REFERENCES groups (id) ON DELETE CASCADE;
+
+ALTER TABLE playlists DROP CONSTRAINT IF EXISTS playlists_tenant_id_id_key;
+ALTER TABLE songs DROP CONSTRAINT IF EXISTS songs_tenant_id_id_key;
+ALTER TABLE groups DROP CONSTRAINT IF EXISTS groups_tenant_id_id_key;
+
+DROP INDEX IF EXISTS idx_songs_group_song_name_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_songs_group_song_name_unique ON songs (group_id, lower(song_name));
+
+ALTER TABLE groups DROP CONSTRAINT IF EXISTS groups_tenant_id_group_name_key;
+ALTER TABLE groups ADD CONSTRAINT groups_group_name_key UNIQUE (group_name);
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE playlist_items DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE playlists DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE enrichment_jobs DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE songs DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE groups DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,73 @@
+-- Every library row belongs to a tenant. Rows created before tenants existed
+-- belong to the default tenant. New rows take the tenant of the session,
+-- which the application sets on every connection it hands out; without one
+-- inserts fail on the NOT NULL constraint.
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE enrichment_jobs ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE playlist_items ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE groups ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('musiclib.tenant_id', true), '');
+ALTER TABLE songs ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('musiclib.tenant_id', true), '');
+ALTER TABLE enrichment_jobs ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('musiclib.tenant_id', true), '');
+ALTER TABLE playlists ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('musiclib.tenant_id', true), '');
+ALTER TABLE playlist_items ALTER COLUMN tenant_id SET DEFAULT NULLIF(current_setting('musiclib.tenant_id', true), '');
+
+-- API keys are looked up before the tenant is known, they only name it
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+
+-- names are unique within a tenant
+ALTER TABLE groups DROP CONSTRAINT IF EXISTS groups_group_name_key;
+ALTER TABLE groups ADD CONSTRAINT groups_tenant_id_group_name_key UNIQUE (tenant_id, group_name);
+
+DROP INDEX IF EXISTS idx_songs_group_song_name_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_songs_group_song_name_unique ON songs (tenant_id, group_id, lower(song_name));
+
+-- references include the tenant, so they never cross tenants
+ALTER TABLE groups ADD CONSTRAINT groups_tenant_id_id_key UNIQUE (tenant_id, id);
+ALTER TABLE songs ADD CONSTRAINT songs_tenant_id_id_key UNIQUE (tenant_id, id);
+ALTER TABLE playlists ADD CONSTRAINT playlists_tenant_id_id_key UNIQUE (tenant_id, id);
+
+ALTER TABLE songs
+    DROP CONSTRAINT IF EXISTS fk_group,
+    ADD CONSTRAINT fk_group FOREIGN KEY (tenant_id, group_id)
+        REFERENCES groups (tenant_id, id) ON DELETE CASCADE;
+
+ALTER TABLE enrichment_jobs
+    DROP CONSTRAINT IF EXISTS enrichment_jobs_song_id_fkey,
+    ADD CONSTRAINT enrichment_jobs_song_id_fkey FOREIGN KEY (tenant_id, song_id)
+        REFERENCES songs (tenant_id, id) ON DELETE CASCADE;
+
+ALTER TABLE playlist_items
+    DROP CONSTRAINT IF EXISTS playlist_items_playlist_id_fkey,
+    DROP CONSTRAINT IF EXISTS playlist_items_song_id_fkey,
+    ADD CONSTRAINT playlist_items_playlist_id_fkey FOREIGN KEY (tenant_id, playlist_id)
+        REFERENCES playlists (tenant_id, id) ON DELETE CASCADE,
+    ADD CONSTRAINT playlist_items_song_id_fkey FOREIGN KEY (tenant_id, song_id)
+        REFERENCES songs (tenant_id, id) ON DELETE CASCADE;
+
+-- A session only sees the rows of its tenant. The enrichment worker claims
+-- jobs of all tenants with musiclib.all_tenants. FORCE applies the policies
+-- to the table owner too; superusers and BYPASSRLS roles still see everything.
+ALTER TABLE groups ENABLE ROW LEVEL SECURITY, FORCE ROW LEVEL SECURITY;
+ALTER TABLE songs ENABLE ROW LEVEL SECURITY, FORCE ROW LEVEL SECURITY;
+ALTER TABLE enrichment_jobs ENABLE ROW LEVEL SECURITY, FORCE ROW LEVEL SECURITY;
+ALTER TABLE playlists ENABLE ROW LEVEL SECURITY, FORCE ROW LEVEL SECURITY;
+ALTER TABLE playlist_items ENABLE ROW LEVEL SECURITY, FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY tenant_isolation ON groups
+    USING (tenant_id = current_setting('musiclib.tenant_id', true)
+        OR current_setting('musiclib.all_tenants', true) = 'on');
+CREATE POLICY tenant_isolation ON songs
+    USING (tenant_id = current_setting('musiclib.tenant_id', true)
+        OR current_setting('musiclib.all_tenants', true) = 'on');
+CREATE POLICY tenant_isolation ON enrichment_jobs
+    USING (tenant_id = current_setting('musiclib.tenant_id', true)
+        OR current_setting('musiclib.all_tenants', true) = 'on');
+CREATE POLICY tenant_isolation ON playlists
+    USING (tenant_id = current_setting('musiclib.tenant_id', true)
+        OR current_setting('musiclib.all_tenants', true) = 'on');
+CREATE POLICY tenant_isolation ON playlist_items
+    USING (tenant_id = current_setting('musiclib.tenant_id', true)
+        OR current_setting('musiclib.all_tenants', true) = 'on');
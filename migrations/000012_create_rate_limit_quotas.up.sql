@@ -0,0 +1,12 @@
+-- Requests counted against daily quotas, per client, budget and UTC day.
+-- Clients are identified by their principal or address, so the table is not
+-- tenant scoped.
+CREATE TABLE IF NOT EXISTS rate_limit_quotas (
+    client TEXT NOT NULL,
+    budget TEXT NOT NULL,
+    day DATE NOT NULL,
+    used INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (client, budget, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_quotas_day ON rate_limit_quotas (day);
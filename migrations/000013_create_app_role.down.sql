@@ -0,0 +1,8 @@
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE SELECT, INSERT, UPDATE, DELETE ON TABLES FROM musiclib_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE USAGE, SELECT ON SEQUENCES FROM musiclib_app;
+
+-- the role may still be used by other databases of the cluster, so it is
+-- only stripped of its privileges here
+REVOKE ALL ON ALL TABLES IN SCHEMA public FROM musiclib_app;
+REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM musiclib_app;
+REVOKE USAGE ON SCHEMA public FROM musiclib_app;
@@ -0,0 +1,30 @@
+-- The service logs in as the owner of the schema, which runs the migrations,
+-- but serves requests as musiclib_app. Unlike a superuser or the owner of a
+-- database created by one, it is subject to row level security. The role
+-- cannot log in, the owner switches to it, so it needs no password.
+--
+-- Creating the role and granting it takes CREATEROLE. Where the owner does
+-- not have it, as on some managed services, an administrator creates
+-- musiclib_app beforehand and grants it to the owner; both steps are then
+-- skipped here.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'musiclib_app') THEN
+        CREATE ROLE musiclib_app NOLOGIN NOSUPERUSER NOBYPASSRLS;
+    END IF;
+    IF NOT pg_has_role(current_user, 'musiclib_app', 'MEMBER') THEN
+        EXECUTE format('GRANT musiclib_app TO %I', current_user);
+    END IF;
+END
+$$;
+
+GRANT USAGE ON SCHEMA public TO musiclib_app;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO musiclib_app;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO musiclib_app;
+
+-- the readiness check reads the schema version, only migrations change it
+REVOKE INSERT, UPDATE, DELETE ON schema_migrations FROM musiclib_app;
+
+-- tables of later migrations are granted too
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO musiclib_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO musiclib_app;
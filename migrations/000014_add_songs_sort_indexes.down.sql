@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_songs_tenant_release_date;
+DROP INDEX IF EXISTS idx_songs_tenant_song_name;
+DROP INDEX IF EXISTS idx_songs_tenant_id;
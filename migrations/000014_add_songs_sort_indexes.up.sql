@@ -0,0 +1,5 @@
+-- keyset pagination reads the songs of a tenant in the order of a sort key
+-- and the id; the expressions match songSortKeys in repositories/pagination.go
+CREATE INDEX IF NOT EXISTS idx_songs_tenant_id ON songs (tenant_id, id);
+CREATE INDEX IF NOT EXISTS idx_songs_tenant_song_name ON songs (tenant_id, song_name, id);
+CREATE INDEX IF NOT EXISTS idx_songs_tenant_release_date ON songs (tenant_id, (COALESCE(release_date, 'infinity'::date)), id);
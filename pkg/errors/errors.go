@@ -1,10 +1,168 @@
 package errors
 
 import (
+	"context"
 	"errors"
+	"net/http"
 )
 
+// Code is a stable, machine-readable identifier of an error kind.
+type Code string
+
+const (
+	CodeInvalidArgument      Code = "invalid_argument"
+	CodeOutOfRange           Code = "out_of_range"
+	CodeNotFound             Code = "not_found"
+	CodeConflict             Code = "conflict"
+	CodeInvalidReference     Code = "invalid_reference"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeUnauthenticated      Code = "unauthenticated"
+	CodePermissionDenied     Code = "permission_denied"
+	CodeRateLimited          Code = "rate_limited"
+	CodeQuotaExceeded        Code = "quota_exceeded"
+	CodeUnavailable          Code = "unavailable"
+	CodeTimeout              Code = "timeout"
+	CodeBadGateway           Code = "bad_gateway"
+	CodeInternal             Code = "internal"
+)
+
+// AppError is an error that knows how it should be reported to API clients.
+type AppError struct {
+	Code    Code
+	Message string
+	Status  int
+	Details map[string]any
+	Err     error
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes the predefined errors usable with errors.Is even after they were
+// copied with details or a cause. A target without a message matches every
+// error with the same code.
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	if !ok {
+		return false
+	}
+	return t.Code == e.Code && (t.Message == "" || t.Message == e.Message)
+}
+
+// WithDetails returns a copy of the error carrying extra details for the client.
+func (e *AppError) WithDetails(details map[string]any) *AppError {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// Wrap returns a copy of the error with err recorded as its cause.
+func (e *AppError) Wrap(err error) *AppError {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+func New(code Code, status int, message string) *AppError {
+	return &AppError{Code: code, Status: status, Message: message}
+}
+
+func InvalidArgument(message string) *AppError {
+	return New(CodeInvalidArgument, http.StatusBadRequest, message)
+}
+
+func NotFound(message string) *AppError {
+	return New(CodeNotFound, http.StatusNotFound, message)
+}
+
+func Conflict(message string) *AppError {
+	return New(CodeConflict, http.StatusConflict, message)
+}
+
+func Unauthenticated(message string) *AppError {
+	return New(CodeUnauthenticated, http.StatusUnauthorized, message)
+}
+
+func PermissionDenied(message string) *AppError {
+	return New(CodePermissionDenied, http.StatusForbidden, message)
+}
+
+func RateLimited(message string) *AppError {
+	return New(CodeRateLimited, http.StatusTooManyRequests, message)
+}
+
+func QuotaExceeded(message string) *AppError {
+	return New(CodeQuotaExceeded, http.StatusTooManyRequests, message)
+}
+
+func Unavailable(message string) *AppError {
+	return New(CodeUnavailable, http.StatusServiceUnavailable, message)
+}
+
+func Timeout(message string) *AppError {
+	return New(CodeTimeout, http.StatusServiceUnavailable, message)
+}
+
+func BadGateway(message string) *AppError {
+	return New(CodeBadGateway, http.StatusBadGateway, message)
+}
+
+// Internal wraps an unexpected error. Its cause is logged but never shown to clients.
+func Internal(err error) *AppError {
+	return &AppError{Code: CodeInternal, Status: http.StatusInternalServerError, Message: "internal server error", Err: err}
+}
+
+// From returns err as an AppError, treating anything unknown as internal.
+// Internal errors caused by the request running out of time are reported as
+// timeouts.
+func From(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) && appErr.Code != CodeInternal {
+		return appErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout("the request took too long").Wrap(err)
+	}
+	if appErr != nil {
+		return appErr
+	}
+	return Internal(err)
+}
+
+// Error kinds, for use with errors.Is.
 var (
-	NotFoundErr   = errors.New("no song found with the given ID")
-	OutOfRangeErr = errors.New("page out of range")
+	ErrInvalidArgument = &AppError{Code: CodeInvalidArgument}
+	ErrNotFound        = &AppError{Code: CodeNotFound}
+	ErrConflict        = &AppError{Code: CodeConflict}
+	ErrUnavailable     = &AppError{Code: CodeUnavailable}
+)
+
+var (
+	NotFoundErr   = NotFound("no song found with the given ID")
+	OutOfRangeErr = New(CodeOutOfRange, http.StatusBadRequest, "page out of range")
+	SongExistsErr = Conflict("song with the given name already exists in the group")
+
+	GroupNotFoundErr = NotFound("no group found with the given ID")
+	GroupExistsErr   = Conflict("group with the given name already exists")
+	GroupHasSongsErr = Conflict("group still has songs")
+
+	JobNotFoundErr = NotFound("no job found with the given ID")
+
+	PlaylistNotFoundErr  = NotFound("no playlist found with the given ID")
+	SongNotInPlaylistErr = NotFound("song is not in the playlist")
+	SongInPlaylistErr    = Conflict("song is already in the playlist")
+	InvalidPositionErr   = InvalidArgument("position is out of range")
+
+	APIKeyNotFoundErr = NotFound("no API key found with the given ID")
+
+	InvalidCursorErr = InvalidArgument("invalid cursor")
+	InvalidSortErr   = InvalidArgument("invalid sort field. Allowed fields: release_date, song_name, group_name, id")
 )